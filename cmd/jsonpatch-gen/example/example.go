@@ -0,0 +1,48 @@
+// Package example holds types whose patch methods are generated by
+// jsonpatch-gen, to test them against the reflection based ones.
+package example
+
+import "time"
+
+//go:generate go run github.com/optiopay/jsonpatch/cmd/jsonpatch-gen
+
+// Order is an order of a customer.
+//
+//jsonpatch:generate
+type Order struct {
+	ID       int             `json:"id"`
+	Customer Customer        `json:"customer"`
+	Shipping *Address        `json:"shipping,omitempty"`
+	Lines    []Line          `json:"lines"`
+	Tags     []string        `json:"tags"`
+	Totals   map[string]int  `json:"totals"`
+	Created  time.Time       `json:"created"`
+	Extra    map[string]Line `json:"extra"`
+	Internal string          `json:"-"`
+	note     string
+}
+
+// Customer is the customer of an order.
+//
+//jsonpatch:generate
+type Customer struct {
+	Name    string  `json:"name"`
+	Address Address `json:"address"`
+}
+
+// Address is a postal address.
+//
+//jsonpatch:generate
+type Address struct {
+	Street string `json:"street"`
+	City   string `json:"city"`
+}
+
+// Line is an order line.
+//
+//jsonpatch:generate
+type Line struct {
+	SKU      string  `json:"sku"`
+	Quantity int     `json:"quantity"`
+	Price    float64 `json:"price"`
+}
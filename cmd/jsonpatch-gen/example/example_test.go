@@ -0,0 +1,136 @@
+package example
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/optiopay/jsonpatch"
+	"github.com/optiopay/jsonpatch/deep"
+)
+
+// reflective has the fields of Order but not its methods, so it is patched
+// through reflection.
+type reflective Order
+
+func newOrder() *Order {
+	return &Order{
+		ID: 1,
+		Customer: Customer{
+			Name:    "Calvin",
+			Address: Address{Street: "Main Street", City: "Berlin"},
+		},
+		Lines:   []Line{{SKU: "a", Quantity: 1, Price: 2.5}, {SKU: "b", Quantity: 2}},
+		Tags:    []string{"gift"},
+		Totals:  map[string]int{"net": 5},
+		Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Extra:   map[string]Line{"x": {SKU: "x"}},
+	}
+}
+
+func TestApplyOp(t *testing.T) {
+	patches := []string{
+		`[{"op": "replace", "path": "/id", "value": 2}]`,
+		`[{"op": "replace", "path": "/customer/name", "value": "Hobbes"}]`,
+		`[{"op": "replace", "path": "/Customer/address/city", "value": "Amsterdam"}]`,
+		`[{"op": "add", "path": "/customer/address", "value": {"street": "Elm Street"}}]`,
+		`[{"op": "add", "path": "/shipping", "value": {"city": "Paris"}}]`,
+		`[{"op": "replace", "path": "/shipping/city", "value": "Paris"}]`,
+		`[{"op": "remove", "path": "/customer"}]`,
+		`[{"op": "replace", "path": "/lines/1/quantity", "value": 3}]`,
+		`[{"op": "add", "path": "/lines/1", "value": {"sku": "c"}}]`,
+		`[{"op": "remove", "path": "/lines/0"}]`,
+		`[{"op": "remove", "path": "/lines/5/sku"}]`,
+		`[{"op": "add", "path": "/tags/-", "value": "fragile"}]`,
+		`[{"op": "replace", "path": "/tags", "value": ["a", "b"]}]`,
+		`[{"op": "add", "path": "/totals/gross", "value": 6}]`,
+		`[{"op": "replace", "path": "/created", "value": "2021-01-02T03:04:05Z"}]`,
+		`[{"op": "replace", "path": "/extra/x/sku", "value": "y"}]`,
+		`[{"op": "test", "path": "/customer/name", "value": "Calvin"}]`,
+		`[{"op": "test", "path": "/customer/name", "value": "Hobbes"}]`,
+		`[{"op": "test", "path": "/lines/0/price", "value": 2.5}]`,
+		`[{"op": "test", "path": "/id", "value": "x"}]`,
+		`[{"op": "test", "path": "/id", "value": 1.5}]`,
+		`[{"op": "test", "path": "/customer/name", "value": 1}]`,
+		`[{"op": "replace", "path": "/id", "value": "one"}]`,
+		`[{"op": "replace", "path": "/customer/unknown", "value": 1}]`,
+		`[{"op": "replace", "path": "/ID", "value": 3}, {"op": "test", "path": "/id", "value": 3}]`,
+	}
+	for _, patch := range patches {
+		generated := newOrder()
+		expected := (*reflective)(newOrder())
+		err := jsonpatch.Apply([]byte(patch), generated)
+		expectedErr := jsonpatch.Apply([]byte(patch), expected)
+		if (err == nil) != (expectedErr == nil) {
+			t.Errorf("%s: expected error %v, got %v", patch, expectedErr, err)
+			continue
+		}
+		if expectedErr == jsonpatch.ErrTestFailed && err != expectedErr {
+			t.Errorf("%s: expected error %v, got %v", patch, expectedErr, err)
+		}
+		if !deep.Equal(generated, (*Order)(expected)) {
+			t.Errorf("%s: %v", patch, deep.Differences(generated, (*Order)(expected)))
+		}
+	}
+}
+
+func TestDeepCopy(t *testing.T) {
+	x := newOrder()
+	x.Shipping = &Address{City: "Paris"}
+	y, err := x.DeepCopy()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deep.Equal(x, y) {
+		t.Fatal(deep.Differences(x, y))
+	}
+	x.Shipping.City = "Rome"
+	x.Lines[0].SKU = "changed"
+	x.Tags[0] = "changed"
+	x.Totals["net"] = 0
+	x.Extra["x"] = Line{}
+	if !deep.Equal(y, newOrder().withShipping(&Address{City: "Paris"})) {
+		t.Fatal("copy shares memory with the original", y)
+	}
+}
+
+func (x *Order) withShipping(a *Address) *Order {
+	x.Shipping = a
+	return x
+}
+
+func TestDiff(t *testing.T) {
+	a := newOrder()
+	b := newOrder()
+	b.Customer.Address.City = "Amsterdam"
+	b.Shipping = &Address{City: "Paris"}
+	b.Lines = b.Lines[:1]
+	b.Totals["gross"] = 6
+
+	ops, err := a.Diff(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []jsonpatch.Patch{
+		{Op: "replace", Path: "/customer/address/city", Value: json.RawMessage(`"Amsterdam"`)},
+		{Op: "replace", Path: "/shipping", Value: json.RawMessage(`{"street":"","city":"Paris"}`)},
+		{Op: "replace", Path: "/lines", Value: json.RawMessage(`[{"sku":"a","quantity":1,"price":2.5}]`)},
+		{Op: "replace", Path: "/totals", Value: json.RawMessage(`{"gross":6,"net":5}`)},
+	}
+	if !reflect.DeepEqual(ops, expected) {
+		t.Fatalf("expected %v, got %v", expected, ops)
+	}
+
+	data, err := json.Marshal(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = jsonpatch.Apply(data, (*reflective)(a))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !deep.Equal(a, b) {
+		t.Fatal(deep.Differences(a, b))
+	}
+}
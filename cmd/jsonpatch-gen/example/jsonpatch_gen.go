@@ -0,0 +1,724 @@
+// Code generated by jsonpatch-gen. DO NOT EDIT.
+
+package example
+
+import (
+	"encoding/json"
+	"github.com/optiopay/jsonpatch"
+	"github.com/optiopay/jsonpatch/deep"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ApplyOp applies the operation p to x. It returns
+// jsonpatch.ErrNotImplemented for operations which are applied through
+// reflection.
+func (x *Order) ApplyOp(p *jsonpatch.Patch) error {
+	switch p.Op {
+	case "add", "replace", "remove", "test":
+	default:
+		return jsonpatch.ErrNotImplemented
+	}
+	path := strings.Trim(p.Path, "/")
+	head, rest := path, ""
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		head, rest = path[:i], path[i+1:]
+	}
+	switch head {
+	case "ID", "id":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v int
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.ID = v
+				return nil
+			case "remove":
+				var v int
+				x.ID = v
+				return nil
+			case "test":
+				var v int
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil || v != x.ID {
+					return jsonpatch.ErrTestFailed
+				}
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		return jsonpatch.ErrNotImplemented
+	case "Customer", "customer":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v Customer
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.Customer = v
+				return nil
+			case "remove":
+				var v Customer
+				x.Customer = v
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		q := *p
+		q.Path = rest
+		return x.Customer.ApplyOp(&q)
+	case "Shipping", "shipping":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v *Address
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.Shipping = v
+				return nil
+			case "remove":
+				var v *Address
+				x.Shipping = v
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		if x.Shipping == nil {
+			return jsonpatch.ErrNotImplemented
+		}
+		q := *p
+		q.Path = rest
+		return x.Shipping.ApplyOp(&q)
+	case "Lines", "lines":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v []Line
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.Lines = v
+				return nil
+			case "remove":
+				var v []Line
+				x.Lines = v
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		index, sub := rest, ""
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			index, sub = rest[:i], rest[i+1:]
+		}
+		n, err := strconv.Atoi(index)
+		if sub == "" || err != nil || n < 0 || n >= len(x.Lines) {
+			return jsonpatch.ErrNotImplemented
+		}
+		q := *p
+		q.Path = sub
+		return x.Lines[n].ApplyOp(&q)
+	case "Tags", "tags":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v []string
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.Tags = v
+				return nil
+			case "remove":
+				var v []string
+				x.Tags = v
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		return jsonpatch.ErrNotImplemented
+	case "Totals", "totals":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v map[string]int
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.Totals = v
+				return nil
+			case "remove":
+				var v map[string]int
+				x.Totals = v
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		return jsonpatch.ErrNotImplemented
+	case "Created", "created":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v time.Time
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.Created = v
+				return nil
+			case "remove":
+				var v time.Time
+				x.Created = v
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		return jsonpatch.ErrNotImplemented
+	case "Extra", "extra":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v map[string]Line
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.Extra = v
+				return nil
+			case "remove":
+				var v map[string]Line
+				x.Extra = v
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		return jsonpatch.ErrNotImplemented
+	case "Internal":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v string
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.Internal = v
+				return nil
+			case "remove":
+				var v string
+				x.Internal = v
+				return nil
+			case "test":
+				var v string
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil || v != x.Internal {
+					return jsonpatch.ErrTestFailed
+				}
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		return jsonpatch.ErrNotImplemented
+	}
+	return jsonpatch.ErrNotImplemented
+}
+
+// DeepCopy returns a deep copy of x.
+func (x *Order) DeepCopy() (*Order, error) {
+	if x == nil {
+		return nil, nil
+	}
+	y := new(Order)
+	err := x.jsonpatchCopy(y)
+	if err != nil {
+		return nil, err
+	}
+	return y, nil
+}
+
+func (x *Order) jsonpatchCopy(y *Order) error {
+	*y = *x
+	if err := x.Customer.jsonpatchCopy(&y.Customer); err != nil {
+		return err
+	}
+	if x.Shipping != nil {
+		y.Shipping = new(Address)
+		if err := x.Shipping.jsonpatchCopy(y.Shipping); err != nil {
+			return err
+		}
+	}
+	if x.Lines != nil {
+		y.Lines = make([]Line, len(x.Lines))
+		for i := range x.Lines {
+			if err := x.Lines[i].jsonpatchCopy(&y.Lines[i]); err != nil {
+				return err
+			}
+		}
+	}
+	if x.Tags != nil {
+		y.Tags = make([]string, len(x.Tags))
+		copy(y.Tags, x.Tags)
+	}
+	if x.Totals != nil {
+		y.Totals = make(map[string]int, len(x.Totals))
+		for k, v := range x.Totals {
+			y.Totals[k] = v
+		}
+	}
+	if err := deep.Copy(&x.Created, &y.Created); err != nil {
+		return err
+	}
+	if err := deep.Copy(&x.Extra, &y.Extra); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Diff returns the patch turning x into y. Fields which differ are replaced
+// as a whole, apart from fields of types generated by jsonpatch-gen, which
+// are compared field by field.
+func (x *Order) Diff(y *Order) ([]jsonpatch.Patch, error) {
+	return x.jsonpatchDiff("", y, nil)
+}
+
+func (x *Order) jsonpatchDiff(prefix string, y *Order, ops []jsonpatch.Patch) ([]jsonpatch.Patch, error) {
+	var err error
+	if x.ID != y.ID {
+		ops, err = jsonpatchReplace(ops, prefix+"/id", y.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ops, err = x.Customer.jsonpatchDiff(prefix+"/customer", &y.Customer, ops)
+	if err != nil {
+		return nil, err
+	}
+	if x.Shipping != nil && y.Shipping != nil {
+		ops, err = x.Shipping.jsonpatchDiff(prefix+"/shipping", y.Shipping, ops)
+		if err != nil {
+			return nil, err
+		}
+	} else if x.Shipping != y.Shipping {
+		ops, err = jsonpatchReplace(ops, prefix+"/shipping", y.Shipping)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !deep.Equal(x.Lines, y.Lines) {
+		ops, err = jsonpatchReplace(ops, prefix+"/lines", y.Lines)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !deep.Equal(x.Tags, y.Tags) {
+		ops, err = jsonpatchReplace(ops, prefix+"/tags", y.Tags)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !deep.Equal(x.Totals, y.Totals) {
+		ops, err = jsonpatchReplace(ops, prefix+"/totals", y.Totals)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !deep.Equal(x.Created, y.Created) {
+		ops, err = jsonpatchReplace(ops, prefix+"/created", y.Created)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if !deep.Equal(x.Extra, y.Extra) {
+		ops, err = jsonpatchReplace(ops, prefix+"/extra", y.Extra)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ops, err
+}
+
+// ApplyOp applies the operation p to x. It returns
+// jsonpatch.ErrNotImplemented for operations which are applied through
+// reflection.
+func (x *Customer) ApplyOp(p *jsonpatch.Patch) error {
+	switch p.Op {
+	case "add", "replace", "remove", "test":
+	default:
+		return jsonpatch.ErrNotImplemented
+	}
+	path := strings.Trim(p.Path, "/")
+	head, rest := path, ""
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		head, rest = path[:i], path[i+1:]
+	}
+	switch head {
+	case "Name", "name":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v string
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.Name = v
+				return nil
+			case "remove":
+				var v string
+				x.Name = v
+				return nil
+			case "test":
+				var v string
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil || v != x.Name {
+					return jsonpatch.ErrTestFailed
+				}
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		return jsonpatch.ErrNotImplemented
+	case "Address", "address":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v Address
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.Address = v
+				return nil
+			case "remove":
+				var v Address
+				x.Address = v
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		q := *p
+		q.Path = rest
+		return x.Address.ApplyOp(&q)
+	}
+	return jsonpatch.ErrNotImplemented
+}
+
+// DeepCopy returns a deep copy of x.
+func (x *Customer) DeepCopy() (*Customer, error) {
+	if x == nil {
+		return nil, nil
+	}
+	y := new(Customer)
+	err := x.jsonpatchCopy(y)
+	if err != nil {
+		return nil, err
+	}
+	return y, nil
+}
+
+func (x *Customer) jsonpatchCopy(y *Customer) error {
+	*y = *x
+	if err := x.Address.jsonpatchCopy(&y.Address); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Diff returns the patch turning x into y. Fields which differ are replaced
+// as a whole, apart from fields of types generated by jsonpatch-gen, which
+// are compared field by field.
+func (x *Customer) Diff(y *Customer) ([]jsonpatch.Patch, error) {
+	return x.jsonpatchDiff("", y, nil)
+}
+
+func (x *Customer) jsonpatchDiff(prefix string, y *Customer, ops []jsonpatch.Patch) ([]jsonpatch.Patch, error) {
+	var err error
+	if x.Name != y.Name {
+		ops, err = jsonpatchReplace(ops, prefix+"/name", y.Name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ops, err = x.Address.jsonpatchDiff(prefix+"/address", &y.Address, ops)
+	if err != nil {
+		return nil, err
+	}
+	return ops, err
+}
+
+// ApplyOp applies the operation p to x. It returns
+// jsonpatch.ErrNotImplemented for operations which are applied through
+// reflection.
+func (x *Address) ApplyOp(p *jsonpatch.Patch) error {
+	switch p.Op {
+	case "add", "replace", "remove", "test":
+	default:
+		return jsonpatch.ErrNotImplemented
+	}
+	path := strings.Trim(p.Path, "/")
+	head, rest := path, ""
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		head, rest = path[:i], path[i+1:]
+	}
+	switch head {
+	case "Street", "street":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v string
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.Street = v
+				return nil
+			case "remove":
+				var v string
+				x.Street = v
+				return nil
+			case "test":
+				var v string
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil || v != x.Street {
+					return jsonpatch.ErrTestFailed
+				}
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		return jsonpatch.ErrNotImplemented
+	case "City", "city":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v string
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.City = v
+				return nil
+			case "remove":
+				var v string
+				x.City = v
+				return nil
+			case "test":
+				var v string
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil || v != x.City {
+					return jsonpatch.ErrTestFailed
+				}
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		return jsonpatch.ErrNotImplemented
+	}
+	return jsonpatch.ErrNotImplemented
+}
+
+// DeepCopy returns a deep copy of x.
+func (x *Address) DeepCopy() (*Address, error) {
+	if x == nil {
+		return nil, nil
+	}
+	y := new(Address)
+	err := x.jsonpatchCopy(y)
+	if err != nil {
+		return nil, err
+	}
+	return y, nil
+}
+
+func (x *Address) jsonpatchCopy(y *Address) error {
+	*y = *x
+	return nil
+}
+
+// Diff returns the patch turning x into y. Fields which differ are replaced
+// as a whole, apart from fields of types generated by jsonpatch-gen, which
+// are compared field by field.
+func (x *Address) Diff(y *Address) ([]jsonpatch.Patch, error) {
+	return x.jsonpatchDiff("", y, nil)
+}
+
+func (x *Address) jsonpatchDiff(prefix string, y *Address, ops []jsonpatch.Patch) ([]jsonpatch.Patch, error) {
+	var err error
+	if x.Street != y.Street {
+		ops, err = jsonpatchReplace(ops, prefix+"/street", y.Street)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if x.City != y.City {
+		ops, err = jsonpatchReplace(ops, prefix+"/city", y.City)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ops, err
+}
+
+// ApplyOp applies the operation p to x. It returns
+// jsonpatch.ErrNotImplemented for operations which are applied through
+// reflection.
+func (x *Line) ApplyOp(p *jsonpatch.Patch) error {
+	switch p.Op {
+	case "add", "replace", "remove", "test":
+	default:
+		return jsonpatch.ErrNotImplemented
+	}
+	path := strings.Trim(p.Path, "/")
+	head, rest := path, ""
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		head, rest = path[:i], path[i+1:]
+	}
+	switch head {
+	case "SKU", "sku":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v string
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.SKU = v
+				return nil
+			case "remove":
+				var v string
+				x.SKU = v
+				return nil
+			case "test":
+				var v string
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil || v != x.SKU {
+					return jsonpatch.ErrTestFailed
+				}
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		return jsonpatch.ErrNotImplemented
+	case "Quantity", "quantity":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v int
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.Quantity = v
+				return nil
+			case "remove":
+				var v int
+				x.Quantity = v
+				return nil
+			case "test":
+				var v int
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil || v != x.Quantity {
+					return jsonpatch.ErrTestFailed
+				}
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		return jsonpatch.ErrNotImplemented
+	case "Price", "price":
+		if rest == "" {
+			switch p.Op {
+			case "add", "replace":
+				var v float64
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil {
+					return err
+				}
+				x.Price = v
+				return nil
+			case "remove":
+				var v float64
+				x.Price = v
+				return nil
+			case "test":
+				var v float64
+				err := json.Unmarshal(p.Value, &v)
+				if err != nil || v != x.Price {
+					return jsonpatch.ErrTestFailed
+				}
+				return nil
+			}
+			return jsonpatch.ErrNotImplemented
+		}
+		return jsonpatch.ErrNotImplemented
+	}
+	return jsonpatch.ErrNotImplemented
+}
+
+// DeepCopy returns a deep copy of x.
+func (x *Line) DeepCopy() (*Line, error) {
+	if x == nil {
+		return nil, nil
+	}
+	y := new(Line)
+	err := x.jsonpatchCopy(y)
+	if err != nil {
+		return nil, err
+	}
+	return y, nil
+}
+
+func (x *Line) jsonpatchCopy(y *Line) error {
+	*y = *x
+	return nil
+}
+
+// Diff returns the patch turning x into y. Fields which differ are replaced
+// as a whole, apart from fields of types generated by jsonpatch-gen, which
+// are compared field by field.
+func (x *Line) Diff(y *Line) ([]jsonpatch.Patch, error) {
+	return x.jsonpatchDiff("", y, nil)
+}
+
+func (x *Line) jsonpatchDiff(prefix string, y *Line, ops []jsonpatch.Patch) ([]jsonpatch.Patch, error) {
+	var err error
+	if x.SKU != y.SKU {
+		ops, err = jsonpatchReplace(ops, prefix+"/sku", y.SKU)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if x.Quantity != y.Quantity {
+		ops, err = jsonpatchReplace(ops, prefix+"/quantity", y.Quantity)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if x.Price != y.Price {
+		ops, err = jsonpatchReplace(ops, prefix+"/price", y.Price)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ops, err
+}
+
+// jsonpatchReplace appends the operation replacing the value at path with v
+// to ops.
+func jsonpatchReplace(ops []jsonpatch.Patch, path string, v interface{}) ([]jsonpatch.Patch, error) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(ops, jsonpatch.Patch{Op: "replace", Path: path, Value: value}), nil
+}
@@ -0,0 +1,614 @@
+// Command jsonpatch-gen generates methods applying JSON patches to struct
+// types without reflection.
+//
+// Usage:
+//
+//	jsonpatch-gen [-o FILE] [DIR]
+//
+// It generates the methods for the struct types of the package in DIR,
+// which defaults to the current directory, whose doc comment contains the
+// line
+//
+//	//jsonpatch:generate
+//
+// and writes them to FILE, which defaults to jsonpatch_gen.go in DIR. It is
+// meant to be run by go generate:
+//
+//	//go:generate jsonpatch-gen
+//
+// For each type T it generates
+//
+//	func (x *T) ApplyOp(p *jsonpatch.Patch) error
+//	func (x *T) DeepCopy() (*T, error)
+//	func (x *T) Diff(y *T) ([]jsonpatch.Patch, error)
+//
+// ApplyOp implements jsonpatch.Patchable. It adds, replaces, removes and
+// tests the exported fields of T by their JSON name or Go name, and passes
+// operations on fields of other generated types on to their ApplyOp
+// method. Everything else, e.g. inserting into slices, is left to be applied
+// through reflection. DeepCopy copies fields of types it does not know with
+// deep.Copy and returns its error. Apply uses it to copy the value before
+// patching.
+//
+// The exit code is 0 on success, 1 if the methods cannot be generated and 2
+// on wrong usage.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Exit codes.
+const (
+	exitOK = iota
+	exitFailed
+	exitUsage
+)
+
+// annotation marks the types the methods are generated for.
+const annotation = "//jsonpatch:generate"
+
+// output is the default name of the generated file.
+const output = "jsonpatch_gen.go"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+// run executes the command line args and returns the exit code.
+func run(args []string, stderr io.Writer) int {
+	flags := flag.NewFlagSet("jsonpatch-gen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	out := flags.String("o", "", "the generated `file`, jsonpatch_gen.go in DIR by default")
+	err := flags.Parse(args)
+	if err != nil {
+		return exitUsage
+	}
+	dir := "."
+	switch flags.NArg() {
+	case 0:
+	case 1:
+		dir = flags.Arg(0)
+	default:
+		fmt.Fprintln(stderr, "usage: jsonpatch-gen [-o FILE] [DIR]")
+		return exitUsage
+	}
+	if *out == "" {
+		*out = filepath.Join(dir, output)
+	}
+
+	src, err := generate(dir, filepath.Base(*out))
+	if err == nil {
+		err = os.WriteFile(*out, src, 0644)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "jsonpatch-gen:", err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+// generate returns the source of the methods of the annotated types of the
+// package in dir, ignoring the file named skip.
+func generate(dir, skip string) ([]byte, error) {
+	fset := token.NewFileSet()
+	names, err := filepath.Glob(filepath.Join(dir, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var files []*ast.File
+	for _, name := range names {
+		if strings.HasSuffix(name, "_test.go") || filepath.Base(name) == skip {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, parser.ParseComments)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	g := &generator{fset: fset, types: map[string]bool{}, imports: map[string]string{}}
+	var specs []*ast.TypeSpec
+	var owners []*ast.File
+	for _, f := range files {
+		for _, decl := range f.Decls {
+			d, ok := decl.(*ast.GenDecl)
+			if !ok || d.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range d.Specs {
+				s := spec.(*ast.TypeSpec)
+				if !annotated(d.Doc) && !annotated(s.Doc) {
+					continue
+				}
+				if _, ok := s.Type.(*ast.StructType); !ok {
+					return nil, fmt.Errorf("%s: %s is not a struct type", fset.Position(s.Pos()), s.Name.Name)
+				}
+				g.types[s.Name.Name] = true
+				specs = append(specs, s)
+				owners = append(owners, f)
+			}
+		}
+	}
+	if len(specs) == 0 {
+		return nil, fmt.Errorf("no types annotated with %s in %s", annotation, dir)
+	}
+	for i, s := range specs {
+		err = g.generate(s, owners[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+	return g.source(files[0].Name.Name)
+}
+
+// annotated reports whether the doc comment contains the annotation.
+func annotated(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+// kind classifies the types of fields by the code generated for them.
+type kind int
+
+const (
+	// other types are copied with deep.Copy and compared with deep.Equal.
+	other kind = iota
+	// basic types are booleans, numbers and strings.
+	basic
+	// generated types are the annotated struct types.
+	generated
+	// generatedPtr types are pointers to generated types.
+	generatedPtr
+	// generatedSlice types are slices of generated types.
+	generatedSlice
+	// basicSlice types are slices of basic types.
+	basicSlice
+	// basicMap types are maps of basic types to basic types.
+	basicMap
+)
+
+var basicTypes = map[string]bool{
+	"bool": true, "string": true, "byte": true, "rune": true, "uintptr": true,
+	"int": true, "int8": true, "int16": true, "int32": true, "int64": true,
+	"uint": true, "uint8": true, "uint16": true, "uint32": true, "uint64": true,
+	"float32": true, "float64": true, "complex64": true, "complex128": true,
+}
+
+// field describes a field of a generated type.
+type field struct {
+	// name is the Go name of the field.
+	name string
+	// json is the name of the field in JSON, empty if it is not encoded.
+	json string
+	// typ is the type of the field as written in the source.
+	typ string
+	// elem is the generated type of the value of generated, generatedPtr
+	// and generatedSlice fields.
+	elem string
+	kind kind
+	// exported is false for unexported and embedded fields, which are
+	// only copied.
+	exported bool
+}
+
+// generator holds the state of the generated file.
+type generator struct {
+	fset *token.FileSet
+	buf  bytes.Buffer
+	// types holds the names of the generated types.
+	types map[string]bool
+	// imports maps the import paths used by the generated code to their
+	// names.
+	imports map[string]string
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+// source returns the formatted source of the file of the package pkg.
+func (g *generator) source(pkg string) ([]byte, error) {
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by jsonpatch-gen. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkg)
+	paths := make([]string, 0, len(g.imports))
+	for path := range g.imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&src, "\t%s %q\n", g.imports[path], path)
+	}
+	src.WriteString(")\n")
+	src.Write(g.buf.Bytes())
+	src.WriteString(`
+// jsonpatchReplace appends the operation replacing the value at path with v
+// to ops.
+func jsonpatchReplace(ops []jsonpatch.Patch, path string, v interface{}) ([]jsonpatch.Patch, error) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(ops, jsonpatch.Patch{Op: "replace", Path: path, Value: value}), nil
+}
+`)
+	return format.Source(src.Bytes())
+}
+
+// use records that the generated code uses the package of the import path.
+func (g *generator) use(path string) {
+	if _, ok := g.imports[path]; !ok {
+		g.imports[path] = ""
+	}
+}
+
+// fields returns the fields of the struct type s declared in the file f.
+func (g *generator) fields(s *ast.TypeSpec, f *ast.File) ([]field, error) {
+	var fields []field
+	for _, fl := range s.Type.(*ast.StructType).Fields.List {
+		var buf bytes.Buffer
+		err := printer.Fprint(&buf, g.fset, fl.Type)
+		if err != nil {
+			return nil, err
+		}
+		err = g.importsOf(fl.Type, f)
+		if err != nil {
+			return nil, err
+		}
+		fd := field{typ: buf.String()}
+		switch t := fl.Type.(type) {
+		case *ast.ChanType, *ast.FuncType:
+			return nil, fmt.Errorf("%s: %s has a field of unsupported type %s", g.fset.Position(fl.Pos()), s.Name.Name, fd.typ)
+		case *ast.Ident:
+			if basicTypes[t.Name] {
+				fd.kind = basic
+			} else if g.types[t.Name] {
+				fd.kind, fd.elem = generated, t.Name
+			}
+		case *ast.StarExpr:
+			if id, ok := t.X.(*ast.Ident); ok && g.types[id.Name] {
+				fd.kind, fd.elem = generatedPtr, id.Name
+			}
+		case *ast.ArrayType:
+			if id, ok := t.Elt.(*ast.Ident); ok && t.Len == nil {
+				if basicTypes[id.Name] {
+					fd.kind = basicSlice
+				} else if g.types[id.Name] {
+					fd.kind, fd.elem = generatedSlice, id.Name
+				}
+			}
+		case *ast.MapType:
+			k, ok := t.Key.(*ast.Ident)
+			v, ok2 := t.Value.(*ast.Ident)
+			if ok && ok2 && basicTypes[k.Name] && basicTypes[v.Name] {
+				fd.kind = basicMap
+			}
+		}
+		if len(fl.Names) == 0 {
+			// embedded fields are named after their type
+			fd.name = strings.TrimPrefix(fd.typ, "*")
+			fd.name = fd.name[strings.LastIndex(fd.name, ".")+1:]
+			fields = append(fields, fd)
+			continue
+		}
+		var tag reflect.StructTag
+		if fl.Tag != nil {
+			value, err := strconv.Unquote(fl.Tag.Value)
+			if err != nil {
+				return nil, err
+			}
+			tag = reflect.StructTag(value)
+		}
+		for _, name := range fl.Names {
+			fd.name = name.Name
+			fd.exported = name.IsExported()
+			fd.json = strings.Split(tag.Get("json"), ",")[0]
+			if fd.json == "" {
+				fd.json = name.Name
+			} else if fd.json == "-" {
+				fd.json = ""
+			}
+			fields = append(fields, fd)
+		}
+	}
+	return fields, nil
+}
+
+// importsOf records the imports of the file f used by the type expression.
+func (g *generator) importsOf(expr ast.Expr, f *ast.File) error {
+	var err error
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		id, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		for _, spec := range f.Imports {
+			path, _ := strconv.Unquote(spec.Path.Value)
+			name := path[strings.LastIndex(path, "/")+1:]
+			if spec.Name != nil {
+				name = spec.Name.Name
+			}
+			if name == id.Name {
+				if spec.Name != nil {
+					g.imports[path] = name
+				} else {
+					g.use(path)
+				}
+				return false
+			}
+		}
+		err = fmt.Errorf("%s: unknown package %s", g.fset.Position(id.Pos()), id.Name)
+		return false
+	})
+	return err
+}
+
+// generate generates the methods of the type s declared in the file f.
+func (g *generator) generate(s *ast.TypeSpec, f *ast.File) error {
+	fields, err := g.fields(s, f)
+	if err != nil {
+		return err
+	}
+	g.use("encoding/json")
+	g.use("github.com/optiopay/jsonpatch")
+	g.applyOp(s.Name.Name, fields)
+	g.deepCopy(s.Name.Name, fields)
+	g.diff(s.Name.Name, fields)
+	return nil
+}
+
+// cases returns the names by which each field is matched in paths, giving
+// Go names precedence over JSON names in the same way as Apply.
+func cases(fields []field) [][]string {
+	taken := map[string]bool{}
+	names := make([][]string, len(fields))
+	for i, f := range fields {
+		if f.exported {
+			taken[f.name] = true
+			names[i] = append(names[i], strconv.Quote(f.name))
+		}
+	}
+	for i, f := range fields {
+		if f.exported && f.json != "" && !taken[f.json] {
+			taken[f.json] = true
+			names[i] = append(names[i], strconv.Quote(f.json))
+		}
+	}
+	return names
+}
+
+func (g *generator) applyOp(name string, fields []field) {
+	g.use("strings")
+	g.printf(`
+// ApplyOp applies the operation p to x. It returns
+// jsonpatch.ErrNotImplemented for operations which are applied through
+// reflection.
+func (x *%s) ApplyOp(p *jsonpatch.Patch) error {
+	switch p.Op {
+	case "add", "replace", "remove", "test":
+	default:
+		return jsonpatch.ErrNotImplemented
+	}
+	path := strings.Trim(p.Path, "/")
+	head, rest := path, ""
+	if i := strings.IndexByte(path, '/'); i >= 0 {
+		head, rest = path[:i], path[i+1:]
+	}
+	switch head {
+`, name)
+	for i, names := range cases(fields) {
+		if len(names) == 0 {
+			continue
+		}
+		f := fields[i]
+		g.printf("case %s:\n", strings.Join(names, ", "))
+		g.printf("if rest == \"\" {\n")
+		g.applyField(f)
+		g.printf("}\n")
+		switch f.kind {
+		case generated:
+			g.printf("q := *p\nq.Path = rest\nreturn x.%s.ApplyOp(&q)\n", f.name)
+		case generatedPtr:
+			g.printf(`if x.%[1]s == nil {
+				return jsonpatch.ErrNotImplemented
+			}
+			q := *p
+			q.Path = rest
+			return x.%[1]s.ApplyOp(&q)
+			`, f.name)
+		case generatedSlice:
+			g.use("strconv")
+			g.printf(`index, sub := rest, ""
+			if i := strings.IndexByte(rest, '/'); i >= 0 {
+				index, sub = rest[:i], rest[i+1:]
+			}
+			n, err := strconv.Atoi(index)
+			if sub == "" || err != nil || n < 0 || n >= len(x.%[1]s) {
+				return jsonpatch.ErrNotImplemented
+			}
+			q := *p
+			q.Path = sub
+			return x.%[1]s[n].ApplyOp(&q)
+			`, f.name)
+		default:
+			g.printf("return jsonpatch.ErrNotImplemented\n")
+		}
+	}
+	g.printf("}\nreturn jsonpatch.ErrNotImplemented\n}\n")
+}
+
+// applyField generates the code applying p to the whole field f.
+func (g *generator) applyField(f field) {
+	g.printf(`switch p.Op {
+	case "add", "replace":
+		var v %[2]s
+		err := json.Unmarshal(p.Value, &v)
+		if err != nil {
+			return err
+		}
+		x.%[1]s = v
+		return nil
+	case "remove":
+		var v %[2]s
+		x.%[1]s = v
+		return nil
+	`, f.name, f.typ)
+	if f.kind == basic {
+		g.printf(`case "test":
+		var v %[2]s
+		err := json.Unmarshal(p.Value, &v)
+		if err != nil || v != x.%[1]s {
+			return jsonpatch.ErrTestFailed
+		}
+		return nil
+		`, f.name, f.typ)
+	}
+	g.printf("}\nreturn jsonpatch.ErrNotImplemented\n")
+}
+
+func (g *generator) deepCopy(name string, fields []field) {
+	g.printf(`
+// DeepCopy returns a deep copy of x.
+func (x *%[1]s) DeepCopy() (*%[1]s, error) {
+	if x == nil {
+		return nil, nil
+	}
+	y := new(%[1]s)
+	err := x.jsonpatchCopy(y)
+	if err != nil {
+		return nil, err
+	}
+	return y, nil
+}
+
+func (x *%[1]s) jsonpatchCopy(y *%[1]s) error {
+	*y = *x
+`, name)
+	for _, f := range fields {
+		switch f.kind {
+		case basic:
+		case generated:
+			g.printf(`if err := x.%[1]s.jsonpatchCopy(&y.%[1]s); err != nil {
+				return err
+			}
+			`, f.name)
+		case generatedPtr:
+			g.printf(`if x.%[1]s != nil {
+				y.%[1]s = new(%[2]s)
+				if err := x.%[1]s.jsonpatchCopy(y.%[1]s); err != nil {
+					return err
+				}
+			}
+			`, f.name, f.elem)
+		case generatedSlice:
+			g.printf(`if x.%[1]s != nil {
+				y.%[1]s = make(%[2]s, len(x.%[1]s))
+				for i := range x.%[1]s {
+					if err := x.%[1]s[i].jsonpatchCopy(&y.%[1]s[i]); err != nil {
+						return err
+					}
+				}
+			}
+			`, f.name, f.typ)
+		case basicSlice:
+			g.printf(`if x.%[1]s != nil {
+				y.%[1]s = make(%[2]s, len(x.%[1]s))
+				copy(y.%[1]s, x.%[1]s)
+			}
+			`, f.name, f.typ)
+		case basicMap:
+			g.printf(`if x.%[1]s != nil {
+				y.%[1]s = make(%[2]s, len(x.%[1]s))
+				for k, v := range x.%[1]s {
+					y.%[1]s[k] = v
+				}
+			}
+			`, f.name, f.typ)
+		default:
+			g.use("github.com/optiopay/jsonpatch/deep")
+			g.printf(`if err := deep.Copy(&x.%[1]s, &y.%[1]s); err != nil {
+				return err
+			}
+			`, f.name)
+		}
+	}
+	g.printf("return nil\n}\n")
+}
+
+func (g *generator) diff(name string, fields []field) {
+	g.printf(`
+// Diff returns the patch turning x into y. Fields which differ are replaced
+// as a whole, apart from fields of types generated by jsonpatch-gen, which
+// are compared field by field.
+func (x *%[1]s) Diff(y *%[1]s) ([]jsonpatch.Patch, error) {
+	return x.jsonpatchDiff("", y, nil)
+}
+
+func (x *%[1]s) jsonpatchDiff(prefix string, y *%[1]s, ops []jsonpatch.Patch) ([]jsonpatch.Patch, error) {
+	var err error
+`, name)
+	replace := func(f field) string {
+		return fmt.Sprintf(`ops, err = jsonpatchReplace(ops, prefix+%q, y.%s)
+		if err != nil {
+			return nil, err
+		}
+		`, "/"+f.json, f.name)
+	}
+	for _, f := range fields {
+		if f.json == "" || !f.exported {
+			continue
+		}
+		switch f.kind {
+		case basic:
+			g.printf("if x.%s != y.%s {\n%s}\n", f.name, f.name, replace(f))
+		case generated:
+			g.printf(`ops, err = x.%[1]s.jsonpatchDiff(prefix+%[2]q, &y.%[1]s, ops)
+			if err != nil {
+				return nil, err
+			}
+			`, f.name, "/"+f.json)
+		case generatedPtr:
+			g.printf(`if x.%[1]s != nil && y.%[1]s != nil {
+				ops, err = x.%[1]s.jsonpatchDiff(prefix+%[2]q, y.%[1]s, ops)
+				if err != nil {
+					return nil, err
+				}
+			} else if x.%[1]s != y.%[1]s {
+				%[3]s}
+			`, f.name, "/"+f.json, replace(f))
+		default:
+			g.use("github.com/optiopay/jsonpatch/deep")
+			g.printf("if !deep.Equal(x.%s, y.%s) {\n%s}\n", f.name, f.name, replace(f))
+		}
+	}
+	g.printf("return ops, err\n}\n")
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	// the generated code of the example package is up to date
+	src, err := generate("example", output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := os.ReadFile(filepath.Join("example", output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, expected) {
+		t.Fatal("example/jsonpatch_gen.go is outdated, run go generate")
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir, err := os.MkdirTemp("", "jsonpatch-gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"ok/a.go":    "package a\n\n//jsonpatch:generate\ntype A struct {\n\tName string\n}\n",
+		"none/a.go":  "package a\n\ntype A struct{}\n",
+		"chan/a.go":  "package a\n\n//jsonpatch:generate\ntype A struct {\n\tC chan int\n}\n",
+		"alias/a.go": "package a\n\n//jsonpatch:generate\ntype A int\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		err = os.MkdirAll(filepath.Dir(path), 0700)
+		if err == nil {
+			err = os.WriteFile(path, []byte(content), 0600)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	out := filepath.Join(dir, "out.go")
+
+	tests := []struct {
+		args   []string
+		code   int
+		stderr string
+	}{
+		{[]string{filepath.Join(dir, "ok")}, exitOK, ""},
+		{[]string{"-o", out, filepath.Join(dir, "ok")}, exitOK, ""},
+		{[]string{filepath.Join(dir, "none")}, exitFailed, "no types annotated"},
+		{[]string{filepath.Join(dir, "chan")}, exitFailed, "unsupported type chan int"},
+		{[]string{filepath.Join(dir, "alias")}, exitFailed, "A is not a struct type"},
+		{[]string{filepath.Join(dir, "missing")}, exitFailed, "no Go files"},
+		{[]string{"a", "b"}, exitUsage, "usage"},
+		{[]string{"-x"}, exitUsage, "not defined"},
+	}
+	for _, tt := range tests {
+		var stderr bytes.Buffer
+		code := run(tt.args, &stderr)
+		if code != tt.code || !strings.Contains(stderr.String(), tt.stderr) {
+			t.Errorf("%v: expected %d %q, got %d %q", tt.args, tt.code, tt.stderr, code, stderr.String())
+		}
+	}
+	for _, name := range []string{filepath.Join(dir, "ok", output), out} {
+		src, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Contains(src, []byte("func (x *A) ApplyOp(p *jsonpatch.Patch) error")) {
+			t.Fatalf("%s: unexpected source\n%s", name, src)
+		}
+	}
+}
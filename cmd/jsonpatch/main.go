@@ -0,0 +1,302 @@
+// Command jsonpatch applies, creates and checks JSON patches of JSON files.
+//
+// Usage:
+//
+//	jsonpatch apply PATCH [DOC]      apply a JSON patch to a document
+//	jsonpatch merge PATCH [DOC]      apply a JSON merge patch to a document
+//	jsonpatch diff A B               create the patch turning A into B
+//	jsonpatch validate PATCH [DOC]   check a patch, and that it applies to DOC
+//	jsonpatch explain PATCH [DOC]    describe what each operation does
+//
+// Files named "-", and DOC when it is omitted, are read from the standard
+// input. Resulting documents and patches are written to the standard output.
+//
+// The exit code is 0 on success, 1 if a test operation failed, 2 on wrong
+// usage, 3 if an input is malformed and 4 if the patch cannot be applied
+// for any other reason.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/optiopay/jsonpatch"
+)
+
+// Exit codes.
+const (
+	exitOK = iota
+	exitTestFailed
+	exitUsage
+	exitMalformed
+	exitFailed
+)
+
+const usage = `usage:
+	jsonpatch apply PATCH [DOC]
+	jsonpatch merge PATCH [DOC]
+	jsonpatch diff A B
+	jsonpatch validate PATCH [DOC]
+	jsonpatch explain PATCH [DOC]
+`
+
+// errMalformed marks errors caused by malformed input.
+type errMalformed struct {
+	name string
+	err  error
+}
+
+func (e *errMalformed) Error() string {
+	return fmt.Sprintf("%s: %v", e.name, e.err)
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) (code int) {
+	// a panic would exit with 2, which is the code of wrong usage
+	defer func() {
+		if r := recover(); r != nil {
+			fmt.Fprintln(stderr, "jsonpatch: internal error:", r)
+			code = exitFailed
+		}
+	}()
+	if len(args) < 2 || len(args) > 3 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	cmd, a, b := args[0], args[1], "-"
+	if len(args) == 3 {
+		b = args[2]
+	} else if cmd == "diff" {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	if a == "-" && b == "-" && (len(args) == 3 || cmd != "validate") {
+		fmt.Fprintln(stderr, "jsonpatch: only one input can be read from the standard input")
+		return exitUsage
+	}
+	in := &inputs{stdin: stdin}
+
+	var out interface{}
+	var err error
+	switch cmd {
+	case "apply":
+		out, err = apply(in, a, b)
+	case "merge":
+		out, err = merge(in, a, b)
+	case "diff":
+		out, err = diff(in, a, b)
+	case "validate":
+		err = validate(in, a, b, len(args) == 3)
+	case "explain":
+		err = explain(in, a, b, stdout)
+	default:
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	if err != nil {
+		fail(stderr, err)
+		return exitCode(err)
+	}
+	if out != nil {
+		data, err := json.Marshal(out)
+		if err != nil {
+			fail(stderr, err)
+			return exitFailed
+		}
+		fmt.Fprintln(stdout, string(data))
+	}
+	return exitOK
+}
+
+// fail prints err prefixed with the program name, unless the errors of the
+// library already carry it.
+func fail(stderr io.Writer, err error) {
+	msg := err.Error()
+	if !strings.HasPrefix(msg, "jsonpatch: ") {
+		msg = "jsonpatch: " + msg
+	}
+	fmt.Fprintln(stderr, msg)
+}
+
+func exitCode(err error) int {
+	if err == jsonpatch.ErrTestFailed {
+		return exitTestFailed
+	}
+	var m *errMalformed
+	if errors.As(err, &m) {
+		return exitMalformed
+	}
+	return exitFailed
+}
+
+// inputs reads the files named on the command line.
+type inputs struct {
+	stdin io.Reader
+}
+
+func (in *inputs) read(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(in.stdin)
+	}
+	return os.ReadFile(name)
+}
+
+// document reads the JSON document in the file name.
+func (in *inputs) document(name string) (interface{}, error) {
+	data, err := in.read(name)
+	if err != nil {
+		return nil, err
+	}
+	var doc interface{}
+	err = json.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, &errMalformed{name, err}
+	}
+	return doc, nil
+}
+
+// patch reads and checks the JSON patch in the file name.
+func (in *inputs) patch(name string) ([]byte, error) {
+	data, err := in.read(name)
+	if err != nil {
+		return nil, err
+	}
+	var patches []jsonpatch.Patch
+	err = json.Unmarshal(data, &patches)
+	if err != nil {
+		return nil, &errMalformed{name, err}
+	}
+	for i, p := range patches {
+		err = check(p)
+		if err != nil {
+			return nil, &errMalformed{name, fmt.Errorf("operation %d: %v", i, err)}
+		}
+	}
+	return data, nil
+}
+
+// check returns an error if the members of p do not match its operation.
+func check(p jsonpatch.Patch) error {
+	if p.Path != "" && p.Path[0] != '/' {
+		return fmt.Errorf("path %q does not start with /", p.Path)
+	}
+	switch p.Op {
+	case "add", "replace", "test":
+		if p.Value == nil {
+			return fmt.Errorf("%s without a value", p.Op)
+		}
+	case "move", "copy":
+		if p.From == "" {
+			return fmt.Errorf("%s without from", p.Op)
+		}
+	case "remove":
+	case "":
+		return errors.New("missing op")
+	default:
+		return fmt.Errorf("unknown op %q", p.Op)
+	}
+	return nil
+}
+
+func apply(in *inputs, patch, doc string) (interface{}, error) {
+	p, err := in.patch(patch)
+	if err != nil {
+		return nil, err
+	}
+	d, err := in.document(doc)
+	if err != nil {
+		return nil, err
+	}
+	err = jsonpatch.Apply(p, &d)
+	return d, err
+}
+
+func merge(in *inputs, patch, doc string) (interface{}, error) {
+	p, err := in.read(patch)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]interface{}
+	err = json.Unmarshal(p, &m)
+	if err != nil || m == nil {
+		return nil, &errMalformed{patch, errors.New("a merge patch has to be an object")}
+	}
+	d, err := in.document(doc)
+	if err != nil {
+		return nil, err
+	}
+	err = jsonpatch.ApplyMergePatch(p, &d, nil)
+	return d, err
+}
+
+func diff(in *inputs, a, b string) (interface{}, error) {
+	x, err := in.document(a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := in.document(b)
+	if err != nil {
+		return nil, err
+	}
+	data, err := jsonpatch.Diff(x, y)
+	return json.RawMessage(data), err
+}
+
+func validate(in *inputs, patch, doc string, withDoc bool) error {
+	p, err := in.patch(patch)
+	if err != nil || !withDoc {
+		return err
+	}
+	d, err := in.document(doc)
+	if err != nil {
+		return err
+	}
+	return jsonpatch.ApplyWithOptions(p, &d, &jsonpatch.ApplyOptions{DryRun: true})
+}
+
+func explain(in *inputs, patch, doc string, w io.Writer) error {
+	p, err := in.patch(patch)
+	if err != nil {
+		return err
+	}
+	d, err := in.document(doc)
+	if err != nil {
+		return err
+	}
+	explanations, err := jsonpatch.Explain(p, &d, nil)
+	for _, e := range explanations {
+		fmt.Fprintln(w, e.Change.Op, e.Pointer, explainValue(e))
+	}
+	return err
+}
+
+// explainValue describes the effect of the operation of e without the
+// names of the Go fields, which are meaningless for JSON documents.
+func explainValue(e jsonpatch.Explanation) string {
+	if e.Err != nil {
+		return "fails: " + e.Err.Error()
+	}
+	if e.Op == "test" {
+		return "equals " + format(e.Old)
+	}
+	return format(e.Old) + " -> " + format(e.New)
+}
+
+func format(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
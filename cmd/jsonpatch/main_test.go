@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := os.MkdirTemp("", "jsonpatch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"doc.json":       `{"name": "hobbes", "tags": ["a"]}`,
+		"other.json":     `{"name": "Calvin", "tags": ["a", "b"]}`,
+		"patch.json":     `[{"op": "replace", "path": "/name", "value": "Calvin"}, {"op": "add", "path": "/tags/-", "value": "b"}]`,
+		"failing.json":   `[{"op": "test", "path": "/name", "value": "Calvin"}]`,
+		"mistyped.json":  `[{"op": "test", "path": "/name", "value": 5}]`,
+		"index.json":     `[{"op": "test", "path": "/tags/5", "value": "a"}]`,
+		"missing.json":   `[{"op": "replace", "path": "/age/years", "value": 6}]`,
+		"noval.json":     `[{"op": "add", "path": "/name"}]`,
+		"scalar.json":    `[{"op": "add", "path": "/name/x", "value": 1}]`,
+		"root.json":      `[{"op": "replace", "path": "", "value": 2}]`,
+		"one.json":       `1`,
+		"merge.json":     `{"name": "Calvin", "tags": null}`,
+		"malformed.json": `{"name": `,
+	}
+	for name, content := range files {
+		err = os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tests := []struct {
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+	}{
+		{[]string{"apply", path("patch.json"), path("doc.json")}, "", exitOK, `{"name":"Calvin","tags":["a","b"]}`},
+		{[]string{"apply", path("patch.json")}, files["doc.json"], exitOK, `{"name":"Calvin","tags":["a","b"]}`},
+		{[]string{"apply", path("failing.json"), path("doc.json")}, "", exitTestFailed, ""},
+		{[]string{"apply", path("mistyped.json"), path("doc.json")}, "", exitTestFailed, ""},
+		{[]string{"apply", path("index.json"), path("doc.json")}, "", exitFailed, ""},
+		{[]string{"apply", path("missing.json"), path("doc.json")}, "", exitFailed, ""},
+		{[]string{"apply", path("scalar.json"), path("doc.json")}, "", exitFailed, ""},
+		{[]string{"apply", path("root.json"), path("one.json")}, "", exitOK, `2`},
+		{[]string{"apply", path("noval.json"), path("doc.json")}, "", exitMalformed, ""},
+		{[]string{"apply", path("patch.json"), path("malformed.json")}, "", exitMalformed, ""},
+		{[]string{"apply", "-", "-"}, "", exitUsage, ""},
+		{[]string{"merge", path("merge.json"), path("doc.json")}, "", exitOK, `{"name":"Calvin"}`},
+		{[]string{"merge", path("patch.json"), path("doc.json")}, "", exitMalformed, ""},
+		{[]string{"diff", path("doc.json"), "-"}, files["other.json"], exitOK, `[{"op":"replace","path":"/name","value":"Calvin"},{"op":"add","path":"/tags/1","value":"b"}]`},
+		{[]string{"diff", path("doc.json")}, "", exitUsage, ""},
+		{[]string{"diff", path("one.json"), "-"}, `[1]`, exitOK, `[{"op":"replace","path":"","value":[1]}]`},
+		{[]string{"validate", path("patch.json")}, "", exitOK, ""},
+		{[]string{"validate", "-"}, files["noval.json"], exitMalformed, ""},
+		{[]string{"validate", path("failing.json"), path("doc.json")}, "", exitTestFailed, ""},
+		{[]string{"explain", path("patch.json"), path("doc.json")}, "", exitOK, "replace /name \"hobbes\" -> \"Calvin\"\nadd /tags/1 (none) -> \"b\""},
+		{[]string{"frobnicate", path("patch.json")}, "", exitUsage, ""},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		code := run(test.args, strings.NewReader(test.stdin), &stdout, &stderr)
+		if code != test.code {
+			t.Errorf("%v: expected exit code %d, got %d: %s", test.args, test.code, code, stderr.String())
+			continue
+		}
+		if strings.HasPrefix(stderr.String(), "jsonpatch: jsonpatch:") {
+			t.Errorf("%v: repeated prefix in %s", test.args, stderr.String())
+		}
+		if code == exitOK && strings.TrimSpace(stdout.String()) != test.stdout {
+			t.Errorf("%v: expected %s, got %s", test.args, test.stdout, stdout.String())
+		}
+	}
+}
@@ -0,0 +1,221 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// Compose merges patches meant to be applied one after another into a
+// single equivalent patch.
+//
+// Redundant operations on the same location are collapsed: consecutive
+// replaces keep the last value, an add followed by a replace adds the final
+// value, a replace followed by a remove becomes the remove, a remove
+// followed by an add becomes a replace and an add followed by a remove of
+// the same array element cancel out. The array indices of the operations in
+// between are adjusted accordingly. Test operations are preserved and no
+// operation is merged across another one reading or modifying the same
+// location.
+//
+// As Compose does not know the value the patch is going to be applied to,
+// it takes numeric path segments for array indices. Applying the composed
+// patch gives the same result as applying the patches in sequence whenever
+// the latter succeeds.
+func Compose(patches ...[]byte) ([]byte, error) {
+	ops := []Patch{}
+	for _, data := range patches {
+		var p []Patch
+		err := json.Unmarshal(data, &p)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, p...)
+	}
+	return json.Marshal(squash(ops))
+}
+
+// squash collapses operations of ops until there is nothing left to merge.
+func squash(ops []Patch) []Patch {
+	for {
+		merged := false
+		for j := 1; j < len(ops) && !merged; j++ {
+			ops, merged = squashInto(ops, j)
+		}
+		if !merged {
+			return ops
+		}
+	}
+}
+
+// squashInto merges ops[j] into an earlier operation on the same location,
+// if there is one it can be merged with.
+func squashInto(ops []Patch, j int) ([]Patch, bool) {
+	switch ops[j].Op {
+	case "add", "replace", "remove":
+	default:
+		return ops, false
+	}
+	// targets[k] is the path of the location ops[j] refers to in the state
+	// before ops[k] is applied.
+	targets := make([][]string, j+1)
+	targets[j] = segments(ops[j].Path)
+	for i := j - 1; i >= 0; i-- {
+		if ops[i].Op == "move" || ops[i].Op == "copy" {
+			// shift does not adjust the from of moves and copies
+			return ops, false
+		}
+		if equalPath(segments(ops[i].Path), targets[i+1]) {
+			return merge(ops, i, j, targets)
+		}
+		var ok bool
+		targets[i], ok = backmap(targets[i+1], &ops[i])
+		if !ok {
+			return ops, false
+		}
+	}
+	return ops, false
+}
+
+// merge collapses ops[i] and ops[j] which refer to the same location.
+func merge(ops []Patch, i, j int, targets [][]string) ([]Patch, bool) {
+	a, b := ops[i], ops[j]
+	nodes := segments(a.Path)
+	_, array := index(nodes[len(nodes)-1])
+	ops = append([]Patch(nil), ops...)
+	switch {
+	case a.Op == "replace" && (b.Op == "replace" || b.Op == "remove"):
+		return without(ops, i), true
+
+	case a.Op == "add" && b.Op == "replace":
+		ops[i].Value = b.Value
+		return without(ops, j), true
+
+	case a.Op == "add" && b.Op == "remove" && array:
+		shift(ops[i+1:j], targets[i+1:j], -1)
+		return without(without(ops, j), i), true
+
+	case a.Op == "remove" && b.Op == "add":
+		ops[i] = Patch{Op: "replace", Path: a.Path, Value: b.Value}
+		if array {
+			shift(ops[i+1:j], targets[i+1:j], 1)
+		}
+		return without(ops, j), true
+	}
+	return ops, false
+}
+
+// backmap returns the path which refers to the location target in the
+// state before op is applied, target being a path valid after op. The
+// second return value is false if op reads or modifies the location, or
+// its effect on the location cannot be determined.
+func backmap(target []string, op *Patch) ([]string, bool) {
+	if op.Op == "move" {
+		// a move removes the value at from and adds it at path
+		prev, ok := backmap(target, &Patch{Op: "add", Path: op.Path})
+		if !ok {
+			return nil, false
+		}
+		return backmap(prev, &Patch{Op: "remove", Path: op.From})
+	}
+	if op.Op == "copy" {
+		// a copy reads the value at from and adds it at path
+		prev, ok := backmap(target, &Patch{Op: "add", Path: op.Path})
+		if !ok {
+			return nil, false
+		}
+		return backmap(prev, &Patch{Op: "test", Path: op.From})
+	}
+	switch op.Op {
+	case "add", "remove", "replace", "test":
+	default:
+		return nil, false
+	}
+	nodes := segments(op.Path)
+	if related(nodes, target) {
+		return nil, false
+	}
+	if op.Op != "add" && op.Op != "remove" {
+		return target, true
+	}
+	n := len(nodes) - 1
+	if n >= len(target) || !equalPath(nodes[:n], target[:n]) {
+		return target, true
+	}
+	if nodes[n] == "-" {
+		// the index the element was appended at is not known
+		return nil, false
+	}
+	at, ok := index(nodes[n])
+	pos, ok2 := index(target[n])
+	if !ok || !ok2 {
+		return target, true
+	}
+	if op.Op == "add" && pos > at {
+		pos--
+	} else if op.Op == "remove" && pos >= at {
+		pos++
+	}
+	target = append([]string(nil), target...)
+	target[n] = strconv.Itoa(pos)
+	return target, true
+}
+
+// shift adjusts the array indices of ops after an element has been removed
+// from (delta -1) or kept in (delta 1) the array during all of them. The
+// element is at targets[k] in the state before ops[k].
+func shift(ops []Patch, targets [][]string, delta int) {
+	for k := range ops {
+		target := targets[k]
+		n := len(target) - 1
+		at, _ := index(target[n])
+		nodes := segments(ops[k].Path)
+		if len(nodes) <= n || !equalPath(nodes[:n], target[:n]) {
+			continue
+		}
+		pos, ok := index(nodes[n])
+		if !ok {
+			continue
+		}
+		if pos > at || (pos == at && delta > 0 && ops[k].Op != "add") {
+			nodes[n] = strconv.Itoa(pos + delta)
+			ops[k].Path = "/" + strings.Join(nodes, "/")
+		}
+	}
+}
+
+// without returns ops with the i-th operation removed.
+func without(ops []Patch, i int) []Patch {
+	return append(ops[:i], ops[i+1:]...)
+}
+
+// index returns the array index node stands for.
+func index(node string) (int, bool) {
+	if node == "" || strings.TrimLeft(node, "0123456789") != "" {
+		return 0, false
+	}
+	i, err := strconv.Atoi(node)
+	return i, err == nil
+}
+
+// equalPath reports whether the paths a and b are the same.
+func equalPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// related reports whether one of the paths is a prefix of the other, i.e.
+// whether modifying one of the locations affects the other.
+func related(a, b []string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return equalPath(a, b[:len(a)])
+}
@@ -0,0 +1,194 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+type testDoc struct {
+	Name  string
+	Tags  []string
+	Items []testItem
+	M     map[string]string
+}
+
+// normalize makes empty slices and maps nil so that documents can be
+// compared regardless of how they were emptied.
+func (d testDoc) normalize() testDoc {
+	if len(d.Tags) == 0 {
+		d.Tags = nil
+	}
+	if len(d.Items) == 0 {
+		d.Items = nil
+	}
+	if len(d.M) == 0 {
+		d.M = nil
+	}
+	return d
+}
+
+// randomOp returns a random operation which can be applied to d.
+func randomOp(r *rand.Rand, d *testDoc) string {
+	word := func() string { return fmt.Sprintf("%q", fmt.Sprint(r.Intn(100))) }
+	key := string("abc"[r.Intn(3)])
+	switch r.Intn(15) {
+	case 0:
+		return fmt.Sprintf(`{"op": "replace", "path": "/name", "value": %s}`, word())
+	case 1:
+		return fmt.Sprintf(`{"op": "add", "path": "/tags/-", "value": %s}`, word())
+	case 2:
+		return fmt.Sprintf(`{"op": "add", "path": "/tags/%d", "value": %s}`, r.Intn(len(d.Tags)+1), word())
+	case 3:
+		if len(d.Tags) > 0 {
+			return fmt.Sprintf(`{"op": "remove", "path": "/tags/%d"}`, r.Intn(len(d.Tags)))
+		}
+	case 4:
+		if len(d.Tags) > 0 {
+			return fmt.Sprintf(`{"op": "replace", "path": "/tags/%d", "value": %s}`, r.Intn(len(d.Tags)), word())
+		}
+	case 5:
+		if len(d.Tags) > 0 {
+			i := r.Intn(len(d.Tags))
+			return fmt.Sprintf(`{"op": "test", "path": "/tags/%d", "value": %q}`, i, d.Tags[i])
+		}
+	case 6:
+		return fmt.Sprintf(`{"op": "add", "path": "/items/%d", "value": {"Name": %s}}`, r.Intn(len(d.Items)+1), word())
+	case 7:
+		if len(d.Items) > 0 {
+			return fmt.Sprintf(`{"op": "remove", "path": "/items/%d"}`, r.Intn(len(d.Items)))
+		}
+	case 8:
+		if len(d.Items) > 0 {
+			return fmt.Sprintf(`{"op": "replace", "path": "/items/%d/price", "value": %d}`, r.Intn(len(d.Items)), r.Intn(100))
+		}
+	case 9:
+		return fmt.Sprintf(`{"op": "add", "path": "/m/%s", "value": %s}`, key, word())
+	case 10:
+		if _, ok := d.M[key]; ok {
+			return fmt.Sprintf(`{"op": "remove", "path": "/m/%s"}`, key)
+		}
+	case 11:
+		if _, ok := d.M[key]; ok {
+			return fmt.Sprintf(`{"op": "replace", "path": "/m/%s", "value": %s}`, key, word())
+		}
+	case 12:
+		if len(d.Tags) > 0 {
+			to := "-"
+			if r.Intn(3) > 0 {
+				to = fmt.Sprint(r.Intn(len(d.Tags)))
+			}
+			return fmt.Sprintf(`{"op": "move", "from": "/tags/%d", "path": "/tags/%s"}`, r.Intn(len(d.Tags)), to)
+		}
+	case 13:
+		if len(d.Tags) > 0 {
+			return fmt.Sprintf(`{"op": "move", "from": "/tags/%d", "path": "/name"}`, r.Intn(len(d.Tags)))
+		}
+	case 14:
+		if _, ok := d.M[key]; ok {
+			return fmt.Sprintf(`{"op": "move", "from": "/m/%s", "path": "/m/%s"}`, key, string("abc"[r.Intn(3)]))
+		}
+	}
+	return fmt.Sprintf(`{"op": "test", "path": "/name", "value": %q}`, d.Name)
+}
+
+// randomPatch returns a random patch of up to n operations and applies it
+// to d.
+func randomPatch(t *testing.T, r *rand.Rand, d *testDoc, n int) []byte {
+	var ops []json.RawMessage
+	for i := r.Intn(n) + 1; i > 0; i-- {
+		op := randomOp(r, d)
+		err := Apply([]byte("["+op+"]"), d)
+		if err != nil {
+			t.Fatal(op, err)
+		}
+		ops = append(ops, json.RawMessage(op))
+	}
+	data, err := json.Marshal(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCompose(t *testing.T) {
+	tests := []struct {
+		patches  []string
+		expected string
+	}{
+		{
+			[]string{
+				`[{"op": "add", "path": "/name", "value": "a"}]`,
+				`[{"op": "replace", "path": "/name", "value": "b"}]`,
+				`[{"op": "replace", "path": "/name", "value": "c"}]`,
+			},
+			`[{"op": "add", "path": "/name", "value": "c"}]`,
+		},
+		{
+			[]string{
+				`[{"op": "add", "path": "/tags/1", "value": "a"}, {"op": "add", "path": "/tags/0", "value": "b"}]`,
+				`[{"op": "replace", "path": "/tags/3", "value": "c"}, {"op": "remove", "path": "/tags/2"}]`,
+			},
+			`[{"op": "add", "path": "/tags/0", "value": "b"}, {"op": "replace", "path": "/tags/2", "value": "c"}]`,
+		},
+		{
+			[]string{
+				`[{"op": "replace", "path": "/m/a", "value": "a"}, {"op": "test", "path": "/m/a", "value": "a"}]`,
+				`[{"op": "remove", "path": "/m/a"}]`,
+			},
+			`[{"op": "replace", "path": "/m/a", "value": "a"}, {"op": "test", "path": "/m/a", "value": "a"}, {"op": "remove", "path": "/m/a"}]`,
+		},
+		{
+			[]string{
+				`[{"op": "remove", "path": "/tags/0"}, {"op": "add", "path": "/tags/0", "value": "a"}]`,
+			},
+			`[{"op": "replace", "path": "/tags/0", "value": "a"}]`,
+		},
+	}
+	for _, test := range tests {
+		var patches [][]byte
+		for _, p := range test.patches {
+			patches = append(patches, []byte(p))
+		}
+		data, err := Compose(patches...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var composed, expected []Patch
+		json.Unmarshal(data, &composed)
+		json.Unmarshal([]byte(test.expected), &expected)
+		if !reflect.DeepEqual(composed, expected) {
+			t.Errorf("composing %v\ngot      %s\nexpected %s", test.patches, data, test.expected)
+		}
+	}
+}
+
+func TestComposeEquivalence(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		var base testDoc
+		randomPatch(t, r, &base, 6)
+
+		// Apply never modifies the value in place, so the documents
+		// can share slices and maps.
+		sequential := base
+		var patches [][]byte
+		for j := r.Intn(4) + 1; j > 0; j-- {
+			patches = append(patches, randomPatch(t, r, &sequential, 5))
+		}
+		data, err := Compose(patches...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		composed := base
+		err = Apply(data, &composed)
+		if err != nil {
+			t.Fatalf("%s\ncomposed from %s\nfailed: %v", data, patches, err)
+		}
+		if !reflect.DeepEqual(composed.normalize(), sequential.normalize()) {
+			t.Fatalf("%s\ncomposed from %s\nresulted in %+v\nexpected %+v", data, patches, composed, sequential)
+		}
+	}
+}
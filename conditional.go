@@ -0,0 +1,79 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// ErrPrecondition is returned by ConditionalApply when the value does not
+// match the expected version.
+type ErrPrecondition struct {
+	Expected string
+	Actual   string
+}
+
+func (e *ErrPrecondition) Error() string {
+	return fmt.Sprintf("jsonpatch: expected version %q, got %q", e.Expected, e.Actual)
+}
+
+// Versioned is implemented by types which keep track of their own version,
+// e.g. in a revision field maintained by the storage.
+type Versioned interface {
+	Version() string
+}
+
+// Hash returns the SHA-256 hash of the canonical JSON encoding of x in hex.
+// The encoding has its object keys sorted, so equal values have the same
+// hash regardless of whether they are structs or maps.
+func Hash(x interface{}) (string, error) {
+	data, err := json.Marshal(x)
+	if err != nil {
+		return "", err
+	}
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	err = d.Decode(&v)
+	if err != nil {
+		return "", err
+	}
+	data, err = json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// ConditionalApply applies a patch to x like Apply, but only if the current
+// version of x is expected. It returns the version of x after the patch.
+//
+// The version of x is given by Version. If the versions do not match, x is
+// left untouched and *ErrPrecondition is returned, which makes
+// ConditionalApply suitable for implementing If-Match.
+func ConditionalApply(data []byte, x interface{}, expected string) (string, error) {
+	actual, err := Version(x)
+	if err != nil {
+		return "", err
+	}
+	if actual != expected {
+		return "", &ErrPrecondition{Expected: expected, Actual: actual}
+	}
+	err = Apply(data, x)
+	if err != nil {
+		return "", err
+	}
+	return Version(x)
+}
+
+// Version returns the version of x, the result of its Version method if it
+// implements Versioned and its Hash otherwise.
+func Version(x interface{}) (string, error) {
+	if v, ok := x.(Versioned); ok {
+		return v.Version(), nil
+	}
+	return Hash(x)
+}
@@ -0,0 +1,87 @@
+package jsonpatch
+
+import (
+	"testing"
+)
+
+type testRevision struct {
+	Name     string
+	Revision string
+}
+
+func (r *testRevision) Version() string {
+	return r.Revision
+}
+
+func TestHash(t *testing.T) {
+	u := testUser{Name: "Calvin", M: map[string]string{"b": "1", "a": "2"}}
+	h1, err := Hash(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := map[string]interface{}{
+		"M": map[string]interface{}{"a": "2", "b": "1"}, "Phones": nil, "Child": nil,
+		"Email": "", "Age": 0, "Name": "Calvin",
+	}
+	h2, err := Hash(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h1 != h2 {
+		t.Fatal("equal values have different hashes", h1, h2)
+	}
+	u.Age = 1
+	h3, _ := Hash(&u)
+	if h1 == h3 {
+		t.Fatal("different values have the same hash")
+	}
+}
+
+func TestConditionalApply(t *testing.T) {
+	u := testUser{Name: "hobbes"}
+	p := []byte(`[{"op": "replace", "path": "/name", "value": "Calvin"}]`)
+	etag, _ := Hash(&u)
+	_, err := ConditionalApply(p, &u, "stale")
+	if _, ok := err.(*ErrPrecondition); !ok {
+		t.Fatal("expected ErrPrecondition, got", err)
+	}
+	if u.Name != "hobbes" {
+		t.Fatal("patch was applied", u)
+	}
+	next, err := ConditionalApply(p, &u, etag)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "Calvin" {
+		t.Fatal("patch was not applied", u)
+	}
+	if h, _ := Hash(&u); next != h || next == etag {
+		t.Fatal("unexpected version", next)
+	}
+
+	r := testRevision{Name: "hobbes", Revision: "1"}
+	p = []byte(`[{"op": "replace", "path": "/revision", "value": "2"}]`)
+	next, err = ConditionalApply(p, &r, "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next != "2" {
+		t.Fatal("expected version 2, got", next)
+	}
+	_, err = ConditionalApply(p, &r, "1")
+	if _, ok := err.(*ErrPrecondition); !ok {
+		t.Fatal("expected ErrPrecondition, got", err)
+	}
+}
+
+func TestVersion(t *testing.T) {
+	u := testUser{Name: "hobbes"}
+	v, err := Version(&u)
+	if h, _ := Hash(&u); err != nil || v != h {
+		t.Fatal("expected the hash, got", v, err)
+	}
+	v, err = Version(&testRevision{Revision: "3"})
+	if err != nil || v != "3" {
+		t.Fatal("expected version 3, got", v, err)
+	}
+}
@@ -0,0 +1,177 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+
+	"github.com/optiopay/jsonpatch/deep"
+)
+
+// Getter is implemented by containers which are not maps, slices or
+// structs, e.g. ordered maps, sets or sparse arrays, to take part in
+// patching. The elements of a container are addressed by a single segment
+// of a JSON Pointer, the key.
+//
+// Apply traverses containers through Get, and delegates the operations on
+// their elements to the Adder, Remover and Replacer methods they implement.
+// Operations they do not implement fail with ErrUnsupported.
+type Getter interface {
+	// Get returns the element key. The second return value is false if
+	// there is none. Elements which are not pointers are modified in a
+	// copy, which is stored back with Replace.
+	Get(key string) (interface{}, bool)
+}
+
+// Adder is implemented by containers supporting the add operation.
+type Adder interface {
+	// Add adds the element key, decoded from the JSON value.
+	Add(key string, value json.RawMessage) error
+}
+
+// Remover is implemented by containers supporting the remove operation.
+type Remover interface {
+	// Remove removes the element key.
+	Remove(key string) error
+}
+
+// Replacer is implemented by containers supporting the replace operation.
+type Replacer interface {
+	// Replace replaces the element key with the JSON value.
+	Replace(key string, value json.RawMessage) error
+}
+
+// containerOf returns the Getter x is, points to or holds, or nil if there
+// is none.
+func containerOf(x reflect.Value) Getter {
+	for {
+		if !x.IsValid() {
+			return nil
+		}
+		if x.CanInterface() {
+			if g, ok := x.Interface().(Getter); ok {
+				return g
+			}
+		}
+		if x.Kind() != reflect.Ptr && x.Kind() != reflect.Interface {
+			break
+		}
+		if x.IsNil() {
+			return nil
+		}
+		x = x.Elem()
+	}
+	if x.CanAddr() && x.Addr().CanInterface() {
+		if g, ok := x.Addr().Interface().(Getter); ok {
+			return g
+		}
+	}
+	return nil
+}
+
+// findIn applies p to the element key of the container c, at the path node
+// below it.
+func findIn(c Getter, key, node string, p *Patch) error {
+	child, ok := c.Get(key)
+	if !ok {
+		return ErrNodeNil
+	}
+	v := reflect.ValueOf(child)
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		return rapply(node, p, v)
+	}
+	r, ok := c.(Replacer)
+	if !ok || child == nil {
+		return &ErrUnsupported{key}
+	}
+	copied := reflect.New(v.Type())
+	copied.Elem().Set(v)
+	err := rapply(node, p, copied)
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(copied.Elem().Interface())
+	if err != nil {
+		return err
+	}
+	return r.Replace(key, value)
+}
+
+// applyIn applies p to the element key of the container c.
+func applyIn(c Getter, key string, p *Patch) error {
+	switch p.Op {
+	case "add":
+		if a, ok := c.(Adder); ok {
+			return a.Add(key, p.Value)
+		}
+	case "remove":
+		if r, ok := c.(Remover); ok {
+			return r.Remove(key)
+		}
+	case "replace":
+		if r, ok := c.(Replacer); ok {
+			return r.Replace(key, p.Value)
+		}
+	case "test":
+		child, ok := c.Get(key)
+		if !ok {
+			return ErrNodeNil
+		}
+		return testJSON(child, p.Value)
+	default:
+		return &ErrUnknownOp{p.Op}
+	}
+	return &ErrUnsupported{key}
+}
+
+// testJSON returns ErrTestFailed unless the JSON encoding of v equals value.
+func testJSON(v interface{}, value json.RawMessage) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var a, b interface{}
+	err = json.Unmarshal(data, &a)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(value, &b)
+	if err != nil {
+		return err
+	}
+	if !deep.Equal(a, b) {
+		return ErrTestFailed
+	}
+	return nil
+}
+
+// transfer applies the copy or move operation p to x by adding the JSON
+// encoding of the value at p.From to path, after removing it for a move.
+func transfer(path string, p *Patch, x reflect.Value) error {
+	from, nodes, err := parsePointer(p.From)
+	if err != nil {
+		return err
+	}
+	if p.Op == "move" && strings.HasPrefix(p.Path+"/", p.From+"/") && p.Path != p.From {
+		// a value cannot be moved into one of its children
+		return ErrIncorrectIndex
+	}
+	v, err := resolveNodes(nodes, x)
+	if err != nil || !v.CanInterface() {
+		return ErrNodeNil
+	}
+	value, err := json.Marshal(v.Interface())
+	if err != nil {
+		return err
+	}
+	if p.Op == "move" {
+		if p.Path == p.From {
+			return nil
+		}
+		err = rapply(from, &Patch{Op: "remove", Path: p.From}, x)
+		if err != nil {
+			return err
+		}
+	}
+	return rapply(path, &Patch{Op: "add", Path: p.Path, Value: value}, x)
+}
@@ -0,0 +1,159 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// testOrdered is an ordered map of strings to addresses.
+type testOrdered struct {
+	keys   []string
+	values map[string]testAddress
+}
+
+func (m *testOrdered) Get(key string) (interface{}, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+func (m *testOrdered) Add(key string, value json.RawMessage) error {
+	var v testAddress
+	err := json.Unmarshal(value, &v)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	if m.values == nil {
+		m.values = map[string]testAddress{}
+	}
+	m.values[key] = v
+	return nil
+}
+
+func (m *testOrdered) Remove(key string) error {
+	if _, ok := m.values[key]; !ok {
+		return ErrNodeNil
+	}
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i:i], m.keys[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+func (m *testOrdered) Replace(key string, value json.RawMessage) error {
+	if _, ok := m.values[key]; !ok {
+		return ErrNodeNil
+	}
+	return m.Add(key, value)
+}
+
+func (m *testOrdered) MarshalJSON() ([]byte, error) {
+	return json.Marshal(m.values)
+}
+
+// testSet is a read-only set of users.
+type testSet map[string]*testUser
+
+func (s testSet) Get(key string) (interface{}, bool) {
+	u, ok := s[key]
+	return u, ok
+}
+
+type testDirectory struct {
+	Addresses testOrdered
+	Users     testSet
+	Home      testAddress
+}
+
+func newTestDirectory() testDirectory {
+	return testDirectory{
+		Addresses: testOrdered{
+			keys:   []string{"home", "work"},
+			values: map[string]testAddress{"home": {City: "Berlin"}, "work": {City: "Amsterdam"}},
+		},
+		Users: testSet{"calvin": {Name: "Calvin"}},
+	}
+}
+
+func TestContainers(t *testing.T) {
+	tests := []struct {
+		patch string
+		err   bool
+		keys  []string
+		check func(d testDirectory) bool
+	}{
+		{`[{"op": "add", "path": "/Addresses/office", "value": {"City": "Paris"}}]`, false,
+			[]string{"home", "work", "office"}, func(d testDirectory) bool { return d.Addresses.values["office"].City == "Paris" }},
+		{`[{"op": "remove", "path": "/Addresses/home"}]`, false,
+			[]string{"work"}, nil},
+		{`[{"op": "replace", "path": "/Addresses/work/City", "value": "Rome"}]`, false,
+			[]string{"home", "work"}, func(d testDirectory) bool { return d.Addresses.values["work"].City == "Rome" }},
+		{`[{"op": "test", "path": "/Addresses/home", "value": {"Street": "", "city": "Berlin"}}]`, false,
+			[]string{"home", "work"}, nil},
+		{`[{"op": "test", "path": "/Addresses/home/City", "value": "Berlin"}]`, false,
+			[]string{"home", "work"}, nil},
+		{`[{"op": "test", "path": "/Addresses/home", "value": {"Street": "", "city": "Rome"}}]`, true, nil, nil},
+		{`[{"op": "move", "from": "/Addresses/home", "path": "/Home"}]`, false,
+			[]string{"work"}, func(d testDirectory) bool { return d.Home.City == "Berlin" }},
+		{`[{"op": "copy", "from": "/Addresses/work", "path": "/Addresses/office"}]`, false,
+			[]string{"home", "work", "office"}, func(d testDirectory) bool { return d.Addresses.values["office"].City == "Amsterdam" }},
+		{`[{"op": "replace", "path": "/Users/calvin/Name", "value": "Hobbes"}]`, false,
+			[]string{"home", "work"}, func(d testDirectory) bool { return d.Users["calvin"].Name == "Hobbes" }},
+		{`[{"op": "add", "path": "/Users/hobbes", "value": {"Name": "Hobbes"}}]`, true, nil, nil},
+		{`[{"op": "remove", "path": "/Addresses/missing"}]`, true, nil, nil},
+		{`[{"op": "replace", "path": "/Addresses/missing/City", "value": "Rome"}]`, true, nil, nil},
+	}
+	for _, tt := range tests {
+		d := newTestDirectory()
+		err := Apply([]byte(tt.patch), &d)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: was supposed to fail", tt.patch)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.patch, err)
+			continue
+		}
+		if !reflect.DeepEqual(d.Addresses.keys, tt.keys) {
+			t.Errorf("%s: expected keys %v, got %v", tt.patch, tt.keys, d.Addresses.keys)
+		}
+		if tt.check != nil && !tt.check(d) {
+			t.Errorf("%s: unexpected result %+v", tt.patch, d)
+		}
+	}
+
+	d := newTestDirectory()
+	err := Apply([]byte(`[{"op": "add", "path": "/Users/hobbes", "value": {}}]`), &d)
+	if _, ok := err.(*ErrUnsupported); !ok {
+		t.Fatal("expected ErrUnsupported, got", err)
+	}
+}
+
+func TestMoveAndCopy(t *testing.T) {
+	u := testUser{Name: "Calvin", Phones: []string{"a", "b"}}
+	p := []byte(`[
+		{"op": "copy", "from": "/Phones/0", "path": "/Phones/-"},
+		{"op": "move", "from": "/Name", "path": "/Phones/0"}
+	]`)
+	err := Apply(p, &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "" || !reflect.DeepEqual(u.Phones, []string{"Calvin", "a", "b", "a"}) {
+		t.Fatal("unexpected result", u)
+	}
+
+	err = Apply([]byte(`[{"op": "copy", "from": "/Missing", "path": "/Name"}]`), &u)
+	if err != ErrNodeNil {
+		t.Fatal("expected ErrNodeNil, got", err)
+	}
+}
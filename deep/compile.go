@@ -0,0 +1,156 @@
+package deep
+
+import (
+	"reflect"
+	"sync"
+)
+
+// copyFunc copies the addressable value x to the settable value y of the
+// same type.
+type copyFunc func(c *copier, x, y reflect.Value) error
+
+// compiledCopiers caches the copyFunc of every type seen so far, nil for
+// types which are copied by the generic reflection based copy.
+var compiledCopiers sync.Map // reflect.Type -> copyFunc
+
+// compiled returns the copyFunc specialized for the type t, building it on
+// first use, or nil if there is none.
+func compiled(t reflect.Type) copyFunc {
+	if fn, ok := compiledCopiers.Load(t); ok {
+		return fn.(copyFunc)
+	}
+	fn := compile(t)
+	compiledCopiers.Store(t, fn)
+	return fn
+}
+
+func compile(t reflect.Type) copyFunc {
+	if plain(t) {
+		return copyPlain
+	}
+	switch t.Kind() {
+	case reflect.Slice:
+		if plain(t.Elem()) {
+			return copyPlainSlice
+		}
+	case reflect.Map:
+		if plain(t.Key()) && plain(t.Elem()) {
+			return copyPlainMap
+		}
+	case reflect.Struct:
+		return compileStruct(t)
+	}
+	return nil
+}
+
+// plain reports whether values of the type t do not reference any memory,
+// so they are copied deeply by assignment.
+func plain(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Bool, reflect.String,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	case reflect.Array:
+		return plain(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if !plain(t.Field(i).Type) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func copyPlain(c *copier, x, y reflect.Value) error {
+	y.Set(x)
+	return nil
+}
+
+func copyPlainSlice(c *copier, x, y reflect.Value) error {
+	if x.IsNil() {
+		y.Set(reflect.Zero(y.Type()))
+		return nil
+	}
+	l := x.Len()
+	key := visit{x.Pointer(), x.Type(), l}
+	if l > 0 {
+		if sl, ok := c.seen[key]; ok {
+			y.Set(sl)
+			return nil
+		}
+	}
+	sl := reflect.MakeSlice(x.Type(), l, l)
+	reflect.Copy(sl, x)
+	y.Set(sl)
+	if l > 0 {
+		c.seen[key] = sl
+	}
+	return nil
+}
+
+func copyPlainMap(c *copier, x, y reflect.Value) error {
+	if x.IsNil() {
+		return nil
+	}
+	key := visit{x.Pointer(), x.Type(), 0}
+	if m, ok := c.seen[key]; ok {
+		y.Set(m)
+		return nil
+	}
+	m := reflect.MakeMapWithSize(x.Type(), x.Len())
+	c.seen[key] = m
+	y.Set(m)
+	iter := x.MapRange()
+	for i := 0; iter.Next(); i++ {
+		if i%checkEvery == 0 && c.ctx.Err() != nil {
+			return c.ctx.Err()
+		}
+		m.SetMapIndex(iter.Key(), iter.Value())
+	}
+	return nil
+}
+
+// compileStruct returns the copyFunc of the struct type t, which assigns
+// the whole struct and then copies only the exported fields which are not
+// plain.
+func compileStruct(t reflect.Type) copyFunc {
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		// unexported fields are copied shallowly by the assignment
+		if f.PkgPath == "" && !plain(f.Type) {
+			fields = append(fields, i)
+		}
+	}
+	return func(c *copier, x, y reflect.Value) error {
+		y.Set(x)
+		for _, i := range fields {
+			vx := x.Field(i)
+			vy := y.Field(i)
+			if vx.Kind() == reflect.Ptr {
+				if vx.IsNil() {
+					continue
+				}
+				p, err := c.pointer(vx)
+				if err != nil {
+					return err
+				}
+				vy.Set(p)
+				continue
+			}
+			if !vx.CanAddr() {
+				vy.Set(vx)
+				continue
+			}
+			err := c.rcopy(vx.Addr(), vy.Addr())
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
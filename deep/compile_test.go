@@ -0,0 +1,117 @@
+package deep
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+type order struct {
+	ID       int
+	Customer customer
+	Lines    []line
+	Notes    []string
+	Totals   map[string]float64
+	Shipping *address
+	Extra    interface{}
+	Parent   *order
+}
+
+type line struct {
+	SKU      string
+	Quantity int
+	Price    [2]int64
+}
+
+func newOrder(lines int) *order {
+	o := &order{
+		ID: 1,
+		Customer: customer{
+			Name:    "Calvin",
+			Address: &address{City: "Berlin"},
+			Tags:    []string{"a", "b"},
+			Meta:    map[string]interface{}{"age": 6.0},
+		},
+		Notes:    []string{"fragile"},
+		Totals:   map[string]float64{},
+		Shipping: &address{City: "Amsterdam"},
+		Extra:    []interface{}{1.0, "a"},
+	}
+	for i := 0; i < lines; i++ {
+		o.Lines = append(o.Lines, line{SKU: strconv.Itoa(i), Quantity: i, Price: [2]int64{int64(i), 0}})
+		o.Totals[strconv.Itoa(i)] = float64(i)
+	}
+	o.Parent = o
+	return o
+}
+
+// reflective copies x to y without the compiled copy functions.
+func reflective(x, y interface{}) error {
+	c := newCopier(context.Background(), nil)
+	c.fast = false
+	c.seen[visit{reflect.ValueOf(x).Pointer(), reflect.TypeOf(x), 0}] = reflect.ValueOf(y)
+	return c.rcopy(reflect.ValueOf(x), reflect.ValueOf(y))
+}
+
+func TestCompiled(t *testing.T) {
+	x := newOrder(3)
+	x.Customer.secret = 1
+	var a, b order
+	err := Copy(x, &a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = reflective(x, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !Equal(&a, &b) || !Equal(&a, x) {
+		t.Fatal("compiled copy differs", Differences(&a, &b))
+	}
+	if a.Parent != &a {
+		t.Fatal("cycle was not preserved")
+	}
+
+	x.Lines[0].SKU = "changed"
+	x.Notes[0] = "changed"
+	x.Totals["0"] = -1
+	x.Customer.Tags[0] = "changed"
+	x.Shipping.City = "changed"
+	if !Equal(&a, &b) {
+		t.Fatal("copy shares memory with the original", Differences(&a, &b))
+	}
+}
+
+func benchmarkCopy(b *testing.B, x interface{}, new func() interface{}) {
+	b.Run("compiled", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			err := Copy(x, new())
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("reflective", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			err := reflective(x, new())
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkCopyNested(b *testing.B) {
+	benchmarkCopy(b, newOrder(10), func() interface{} { return &order{} })
+}
+
+func BenchmarkCopySlice(b *testing.B) {
+	x := newOrder(10000).Lines
+	benchmarkCopy(b, &x, func() interface{} { return &[]line{} })
+}
+
+func BenchmarkCopyMap(b *testing.B) {
+	x := newOrder(10000).Totals
+	benchmarkCopy(b, &x, func() interface{} { return &map[string]float64{} })
+}
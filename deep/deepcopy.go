@@ -1,10 +1,11 @@
 package deep
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"reflect"
-	"strings"
+	"unsafe"
 )
 
 var (
@@ -13,11 +14,36 @@ var (
 	ErrUnsupported    = errors.New("deep: unsupported kind")
 )
 
+// checkEvery is the number of slice or map elements copied between checks
+// whether the context is done.
+const checkEvery = 1024
+
 // Copy makes a recursive deep copy of obj x to y
 //
-// It does not recurse into map keys. Due to restrictions in the reflect package,
-// only types with all public members may be copied.
+// It does not recurse into map keys. Values held by interfaces are copied
+// according to their dynamic type. Unexported struct fields are copied
+// shallowly, see CopyWithOptions for copying them deeply.
+//
+// Pointers, maps and slices referenced more than once within x are copied
+// once and referenced the same way within y, so cyclic structures can be
+// copied. Slices are considered the same only if they start at the same
+// element and have the same length.
+//
+// The way values of each type are copied is worked out once and cached, so
+// e.g. slices and maps of primitives and structs without references are
+// copied without inspecting every element.
 func Copy(x, y interface{}) error {
+	return CopyContext(context.Background(), x, y)
+}
+
+// CopyContext makes a recursive deep copy of obj x to y in the same way as
+// Copy. It stops copying large slices and maps and returns ctx.Err() once
+// ctx is done.
+func CopyContext(ctx context.Context, x, y interface{}) error {
+	return copyWith(ctx, x, y, nil)
+}
+
+func copyWith(ctx context.Context, x, y interface{}, opts *Options) error {
 	rx := reflect.ValueOf(x)
 	if rx.Kind() != reflect.Ptr {
 		return ErrNonPointer
@@ -34,10 +60,40 @@ func Copy(x, y interface{}) error {
 	if rx.Kind() != ry.Kind() {
 		return ErrDifferentKinds
 	}
-	return rcopy(rx, ry)
+	c := newCopier(ctx, opts)
+	if !ry.IsNil() && rx.Type() == ry.Type() {
+		// references back to x become references to y
+		c.seen[visit{rx.Pointer(), rx.Type(), 0}] = ry
+	}
+	return c.rcopy(rx, ry)
+}
+
+// visit identifies a pointer, map or slice which has been copied.
+type visit struct {
+	addr uintptr
+	typ  reflect.Type
+	// len is the length of slices.
+	len int
+}
+
+// copier holds the state of a single copy.
+type copier struct {
+	ctx context.Context
+	// seen maps the pointers, maps and slices copied so far to their
+	// copies.
+	seen map[visit]reflect.Value
+	// unexported enables copying unexported fields.
+	unexported bool
+	// opts holds the custom ways of copying values, if any.
+	opts *Options
+	// root is true until the value passed to Copy has been reached.
+	root bool
+	// fast enables the copy functions compiled per type, which are only
+	// used without options.
+	fast bool
 }
 
-func rcopy(x, y reflect.Value) error {
+func (c *copier) rcopy(x, y reflect.Value) error {
 	if x.Kind() == reflect.Ptr {
 		x = x.Elem()
 	}
@@ -51,26 +107,65 @@ func rcopy(x, y reflect.Value) error {
 		}
 		y = y.Elem()
 	}
+	root := c.root
+	c.root = false
+	if c.opts != nil {
+		v, ok, err := c.custom(x, root)
+		if ok {
+			if err == nil {
+				y.Set(v)
+			}
+			return err
+		}
+	}
+	if c.fast && x.IsValid() {
+		if fn := compiled(x.Type()); fn != nil {
+			return fn(c, x, y)
+		}
+	}
 	var err error
 	switch x.Kind() {
 	case reflect.Slice, reflect.Array:
-		err = copyArray(x.Addr(), y.Addr())
+		err = c.copyArray(x.Addr(), y.Addr())
 	case reflect.Map:
-		err = copyMap(x.Addr(), y.Addr())
+		err = c.copyMap(x.Addr(), y.Addr())
 	case reflect.Struct:
-		err = copyStruct(x.Addr(), y.Addr())
+		err = c.copyStruct(x.Addr(), y.Addr())
 	case reflect.Ptr:
-		vx := x.Elem()
-		y.Set(reflect.New(vx.Type()))
-		vy := y.Elem()
-		if !vx.CanAddr() || !vy.CanAddr() {
-			vy.Set(vx)
+		if x.IsNil() {
+			y.Set(reflect.Zero(y.Type()))
+			return nil
+		}
+		p, err := c.pointer(x)
+		if err != nil {
+			return err
+		}
+		y.Set(p)
+
+	case reflect.Interface:
+		if x.IsNil() {
+			y.Set(reflect.Zero(y.Type()))
+			return nil
+		}
+		// the value held by an interface is not addressable, thus it is
+		// copied from a temporary one
+		vx := reflect.New(x.Elem().Type())
+		vx.Elem().Set(x.Elem())
+		vy := reflect.New(x.Elem().Type())
+		err = c.rcopy(vx, vy)
+		if err != nil {
+			return err
+		}
+		y.Set(vy.Elem())
+
+	case reflect.Chan, reflect.Func, reflect.UnsafePointer:
+		if c.opts != nil && c.opts.SkipUnsupported {
+			y.Set(x)
 			return nil
 		}
-		err = rcopy(vx.Addr(), vy.Addr())
+		err = ErrUnsupported
 
-	case reflect.Invalid, reflect.Chan, reflect.Func, reflect.Interface, reflect.UnsafePointer:
-		// TODO:
+	case reflect.Invalid:
 		err = ErrUnsupported
 
 	default:
@@ -83,7 +178,27 @@ func rcopy(x, y reflect.Value) error {
 	return err
 }
 
-func copyArray(x, y reflect.Value) error {
+// pointer returns the copy of the value the non-nil pointer x points to,
+// copying it unless it has been copied before.
+func (c *copier) pointer(x reflect.Value) (reflect.Value, error) {
+	key := visit{x.Pointer(), x.Type(), 0}
+	if y, ok := c.seen[key]; ok {
+		return y, nil
+	}
+	if c.opts != nil {
+		y, ok, err := c.custom(x, false)
+		if ok {
+			c.seen[key] = y
+			return y, err
+		}
+	}
+	y := reflect.New(x.Type().Elem())
+	c.seen[key] = y
+	err := c.rcopy(x, y)
+	return y, err
+}
+
+func (c *copier) copyArray(x, y reflect.Value) error {
 	if x.Kind() == reflect.Ptr {
 		x = x.Elem()
 	}
@@ -93,17 +208,38 @@ func copyArray(x, y reflect.Value) error {
 
 	l := x.Len()
 	if x.Kind() == reflect.Slice {
+		if x.IsNil() {
+			y.Set(reflect.Zero(y.Type()))
+			return nil
+		}
+		key := visit{x.Pointer(), x.Type(), l}
+		if l > 0 {
+			if sl, ok := c.seen[key]; ok {
+				y.Set(sl)
+				return nil
+			}
+		}
 		sl := reflect.MakeSlice(x.Type(), l, l)
 		y.Set(sl)
+		if l > 0 {
+			c.seen[key] = sl
+		}
 	}
 	for i := 0; i < l; i++ {
+		if i%checkEvery == 0 && c.ctx.Err() != nil {
+			return c.ctx.Err()
+		}
 		vx := x.Index(i)
 		vy := y.Index(i)
 		if vx.Kind() == reflect.Ptr {
-			err := rcopy(vx, vy)
+			if vx.IsNil() {
+				continue
+			}
+			p, err := c.pointer(vx)
 			if err != nil {
 				return err
 			}
+			vy.Set(p)
 			continue
 		}
 		if !vx.CanAddr() {
@@ -113,7 +249,7 @@ func copyArray(x, y reflect.Value) error {
 		if !vy.CanAddr() {
 			vy.Set(reflect.ValueOf(vx.Interface()))
 		}
-		err := rcopy(vx.Addr(), vy.Addr())
+		err := c.rcopy(vx.Addr(), vy.Addr())
 		if err != nil {
 			return err
 		}
@@ -121,7 +257,7 @@ func copyArray(x, y reflect.Value) error {
 	return nil
 }
 
-func copyMap(x, y reflect.Value) error {
+func (c *copier) copyMap(x, y reflect.Value) error {
 	if x.Kind() == reflect.Ptr {
 		x = reflect.Indirect(x)
 	}
@@ -131,42 +267,50 @@ func copyMap(x, y reflect.Value) error {
 	if x.IsNil() {
 		return nil
 	}
+	key := visit{x.Pointer(), x.Type(), 0}
+	if m, ok := c.seen[key]; ok {
+		y.Set(m)
+		return nil
+	}
 
 	keys := x.MapKeys()
-	y.Set(reflect.MakeMap(x.Type()))
-	for _, key := range keys {
+	m := reflect.MakeMap(x.Type())
+	c.seen[key] = m
+	y.Set(m)
+	for i, key := range keys {
+		if i%checkEvery == 0 && c.ctx.Err() != nil {
+			return c.ctx.Err()
+		}
 		vx := x.MapIndex(key)
-		vy := y.MapIndex(key)
-		if vx.Kind() == reflect.Ptr {
-			el := vx.Elem()
-			if !el.IsValid() {
+		switch vx.Kind() {
+		case reflect.Ptr:
+			if vx.IsNil() {
 				continue
 			}
-			y.SetMapIndex(key, reflect.New(el.Type()))
-			vy = y.MapIndex(key)
-			err := rcopy(vx, vy)
+			p, err := c.pointer(vx)
 			if err != nil {
 				return err
 			}
-			continue
-		}
-		if !vx.CanAddr() {
-			y.SetMapIndex(key, vx)
-			continue
-		}
-		if !vy.CanAddr() {
-			vy = vx
-		}
-		err := rcopy(vx.Addr(), vy.Addr())
-		if err != nil {
-			return err
+			m.SetMapIndex(key, p)
+		case reflect.Slice, reflect.Array, reflect.Map, reflect.Struct, reflect.Interface:
+			// map elements are not addressable, thus they are copied
+			// from a temporary value
+			tx := reflect.New(vx.Type())
+			tx.Elem().Set(vx)
+			ty := reflect.New(vx.Type())
+			err := c.rcopy(tx, ty)
+			if err != nil {
+				return err
+			}
+			m.SetMapIndex(key, ty.Elem())
+		default:
+			m.SetMapIndex(key, vx)
 		}
-		y.SetMapIndex(key, vy)
 	}
 	return nil
 }
 
-func copyStruct(x, y reflect.Value) error {
+func (c *copier) copyStruct(x, y reflect.Value) error {
 	if x.Kind() == reflect.Ptr {
 		x = reflect.Indirect(x)
 	}
@@ -175,7 +319,7 @@ func copyStruct(x, y reflect.Value) error {
 	}
 
 	if x.Kind() == reflect.Ptr {
-		return rcopy(x, y)
+		return c.rcopy(x, y)
 	}
 
 	n := x.Type().NumField()
@@ -185,25 +329,26 @@ func copyStruct(x, y reflect.Value) error {
 	}
 	for i := 0; i < n; i++ {
 		st := x.Type().Field(i)
-		if st.Anonymous {
-			continue
-		}
-		// Ignore private fields
-		if string(st.Name[0]) != strings.ToUpper(string(st.Name[0])) {
-			continue
-		}
 		vx := x.Field(i)
 		vy := y.Field(i)
+		// Unexported fields, including embedded structs of unexported
+		// types, have been copied shallowly along with the struct.
+		if st.PkgPath != "" {
+			if !c.unexported {
+				continue
+			}
+			vx = settable(vx)
+			vy = settable(vy)
+		}
 		if vx.Kind() == reflect.Ptr {
-			el := vx.Elem()
-			if !el.IsValid() {
+			if vx.IsNil() {
 				continue
 			}
-			vy.Set(reflect.New(el.Type()))
-			err := rcopy(vx, vy)
+			p, err := c.pointer(vx)
 			if err != nil {
 				return err
 			}
+			vy.Set(p)
 			continue
 		}
 		if !vx.CanAddr() {
@@ -213,7 +358,7 @@ func copyStruct(x, y reflect.Value) error {
 		if !vy.CanAddr() {
 			vy = vx
 		}
-		err := rcopy(vx.Addr(), vy.Addr())
+		err := c.rcopy(vx.Addr(), vy.Addr())
 		if err != nil {
 			return err
 		}
@@ -221,6 +366,12 @@ func copyStruct(x, y reflect.Value) error {
 	return nil
 }
 
+// settable returns the addressable value v, which may have been obtained
+// through unexported fields, as a value which can be set.
+func settable(v reflect.Value) reflect.Value {
+	return reflect.NewAt(v.Type(), unsafe.Pointer(v.UnsafeAddr())).Elem()
+}
+
 func copyPrimitives(x, y reflect.Value) error {
 	if x.Kind() == reflect.Ptr {
 		x = reflect.Indirect(x)
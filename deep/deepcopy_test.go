@@ -1,6 +1,8 @@
 package deep
 
 import (
+	"context"
+	"errors"
 	"testing"
 )
 
@@ -62,6 +64,39 @@ func TestMapPtr(t *testing.T) {
 	}
 }
 
+func TestMapOfSlices(t *testing.T) {
+	a := map[string][]int{"a": {1, 2}}
+	var b map[string][]int
+	err := Copy(&a, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a["a"][0] = 3
+	if b["a"][0] != 1 {
+		t.Fatal(b)
+	}
+}
+
+func TestInterface(t *testing.T) {
+	var a interface{} = map[string]interface{}{
+		"a": []interface{}{"x", map[string]interface{}{"b": 1.0}},
+		"c": nil,
+	}
+	var b interface{}
+	err := Copy(&a, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.(map[string]interface{})["a"].([]interface{})[1].(map[string]interface{})["b"] = 2.0
+	m := b.(map[string]interface{})
+	if m["a"].([]interface{})[1].(map[string]interface{})["b"] != 1.0 {
+		t.Fatal(b)
+	}
+	if v, ok := m["c"]; !ok || v != nil {
+		t.Fatal(b)
+	}
+}
+
 func TestStruct(t *testing.T) {
 	type s struct {
 		A string
@@ -136,3 +171,161 @@ func TestPrimitives(t *testing.T) {
 		t.Fatal(fb, "not the same as", fb)
 	}
 }
+
+func TestCopyContext(t *testing.T) {
+	a := make(map[int][]int)
+	for i := 0; i < 10; i++ {
+		a[i] = make([]int, 10*checkEvery)
+	}
+	var b map[int][]int
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := CopyContext(ctx, &a, &b)
+	if err != context.Canceled {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+	err = CopyContext(context.Background(), &a, &b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b) != len(a) || len(b[0]) != len(a[0]) {
+		t.Fatal("copy incomplete")
+	}
+}
+
+type node struct {
+	Value    int
+	Next     *node
+	Prev     *node
+	Children []*node
+	Parent   *node
+}
+
+func TestCycles(t *testing.T) {
+	// a doubly linked ring a <-> b <-> c <-> a
+	a, b, c := &node{Value: 1}, &node{Value: 2}, &node{Value: 3}
+	a.Next, b.Next, c.Next = b, c, a
+	a.Prev, b.Prev, c.Prev = c, a, b
+	var y node
+	err := Copy(a, &y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y.Next.Next.Next != &y || y.Prev != y.Next.Next || y.Next.Prev != &y {
+		t.Fatal("ring was not reproduced")
+	}
+	if y.Next == b || y.Next.Value != 2 || y.Prev.Value != 3 {
+		t.Fatal("ring was not copied")
+	}
+}
+
+func TestSharedPointers(t *testing.T) {
+	// a graph in which every child points back to the root and two
+	// children share a grandchild
+	root := &node{Value: 1}
+	shared := &node{Value: 4}
+	for i := 2; i <= 3; i++ {
+		child := &node{Value: i, Parent: root, Children: []*node{shared}}
+		root.Children = append(root.Children, child)
+	}
+	shared.Parent = root.Children[0]
+	graph := map[string]*node{"root": root, "shared": shared}
+	var y map[string]*node
+	err := Copy(&graph, &y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := y["root"]
+	if r == root || r.Children[0].Parent != r || r.Children[1].Parent != r {
+		t.Fatal("back pointers were not reproduced")
+	}
+	s := r.Children[0].Children[0]
+	if s != r.Children[1].Children[0] || s != y["shared"] || s == shared {
+		t.Fatal("shared pointer was not reproduced")
+	}
+	if s.Parent != r.Children[0] {
+		t.Fatal("cycle through the shared pointer was not reproduced")
+	}
+}
+
+func TestSharedSlicesAndMaps(t *testing.T) {
+	type doc struct {
+		A, B []int
+		M, N map[string]int
+	}
+	s := []int{1, 2}
+	m := map[string]int{"a": 1}
+	x := doc{A: s, B: s, M: m, N: m}
+	var y doc
+	err := Copy(&x, &y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	y.A[0] = 3
+	y.M["a"] = 3
+	if y.B[0] != 3 || y.N["a"] != 3 {
+		t.Fatal("aliasing was not reproduced", y)
+	}
+	if s[0] != 1 || m["a"] != 1 {
+		t.Fatal("copy shares memory with the original", x)
+	}
+}
+
+type Base struct {
+	ID   int
+	Tags []string
+}
+
+type entity struct {
+	Base
+	*Meta
+	Payload interface{}
+	Err     error
+	secret  []int
+}
+
+type Meta struct {
+	Owner string
+}
+
+func TestEmbeddedAndInterfaces(t *testing.T) {
+	x := entity{
+		Base:    Base{ID: 1, Tags: []string{"a"}},
+		Meta:    &Meta{Owner: "hobbes"},
+		Payload: map[string]interface{}{"k": []interface{}{1.0}},
+		Err:     errors.New("failed"),
+		secret:  []int{1},
+	}
+	var y entity
+	err := Copy(&x, &y)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x.Tags[0] = "b"
+	x.Owner = "Calvin"
+	x.Payload.(map[string]interface{})["k"].([]interface{})[0] = 2.0
+	if y.ID != 1 || y.Tags[0] != "a" || y.Owner != "hobbes" {
+		t.Fatal("embedded structs were not copied", y)
+	}
+	if y.Payload.(map[string]interface{})["k"].([]interface{})[0] != 1.0 {
+		t.Fatal("interface was not copied", y.Payload)
+	}
+	if y.Err == nil || y.Err.Error() != "failed" {
+		t.Fatal("error was not copied", y.Err)
+	}
+	// unexported fields are copied shallowly by default
+	x.secret[0] = 2
+	if y.secret[0] != 2 {
+		t.Fatal("unexported field was copied deeply", y.secret)
+	}
+
+	var z entity
+	err = CopyWithOptions(&x, &z, &Options{Unexported: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	x.secret[0] = 3
+	if z.secret[0] != 2 {
+		t.Fatal("unexported field was not copied", z.secret)
+	}
+}
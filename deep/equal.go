@@ -0,0 +1,289 @@
+package deep
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Difference describes a location at which two values differ.
+type Difference struct {
+	// Pointer is the JSON Pointer of the location.
+	Pointer string
+	// Field is the Go expression of the location, e.g. Customer.Tags[2].
+	Field string
+	// A and B are the values at the location, nil if it does not exist
+	// in the value.
+	A, B interface{}
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %v != %v", d.Field, d.A, d.B)
+}
+
+// CompareOptions configures EqualWithOptions and DifferencesWithOptions.
+type CompareOptions struct {
+	// NilEqualsEmpty makes nil slices and maps equal to empty ones.
+	NilEqualsEmpty bool
+	// IgnoreUnexported skips unexported struct fields.
+	IgnoreUnexported bool
+}
+
+// Equal reports whether a and b are deeply equal. By default it follows the
+// same rules as reflect.DeepEqual.
+func Equal(a, b interface{}) bool {
+	return EqualWithOptions(a, b, nil)
+}
+
+// EqualWithOptions reports whether a and b are deeply equal, configured by
+// opts. opts may be nil.
+func EqualWithOptions(a, b interface{}, opts *CompareOptions) bool {
+	c := newComparer(opts)
+	c.first = true
+	c.compare(reflect.ValueOf(a), reflect.ValueOf(b), "", root(a))
+	return len(c.differences) == 0
+}
+
+// Differences returns the locations at which a and b differ, in the same
+// way as Equal compares them.
+func Differences(a, b interface{}) []Difference {
+	return DifferencesWithOptions(a, b, nil)
+}
+
+// DifferencesWithOptions returns the locations at which a and b differ,
+// configured by opts. opts may be nil.
+func DifferencesWithOptions(a, b interface{}, opts *CompareOptions) []Difference {
+	c := newComparer(opts)
+	c.compare(reflect.ValueOf(a), reflect.ValueOf(b), "", root(a))
+	return c.differences
+}
+
+// root returns the Go expression of the value x.
+func root(x interface{}) string {
+	t := reflect.TypeOf(x)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Name() == "" {
+		return "value"
+	}
+	return t.Name()
+}
+
+// comparison identifies a pair of pointers, maps or slices which are being
+// compared.
+type comparison struct {
+	a, b uintptr
+	typ  reflect.Type
+}
+
+// comparer holds the state of a single comparison.
+type comparer struct {
+	opts CompareOptions
+	// first stops the comparison at the first difference.
+	first       bool
+	differences []Difference
+	// seen holds the comparisons in progress, which are assumed equal
+	// when they are reached again through a cycle.
+	seen map[comparison]bool
+}
+
+func newComparer(opts *CompareOptions) *comparer {
+	c := &comparer{seen: map[comparison]bool{}}
+	if opts != nil {
+		c.opts = *opts
+	}
+	return c
+}
+
+// escaper escapes the segments of JSON Pointers as defined in RFC 6901.
+var escaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+func (c *comparer) differ(a, b reflect.Value, pointer, field string) {
+	c.differences = append(c.differences, Difference{
+		Pointer: pointer,
+		Field:   field,
+		A:       valueOf(a),
+		B:       valueOf(b),
+	})
+}
+
+// done reports whether the comparison can stop.
+func (c *comparer) done() bool {
+	return c.first && len(c.differences) > 0
+}
+
+func (c *comparer) compare(a, b reflect.Value, pointer, field string) {
+	if !a.IsValid() || !b.IsValid() {
+		if a.IsValid() != b.IsValid() {
+			c.differ(a, b, pointer, field)
+		}
+		return
+	}
+	if a.Type() != b.Type() {
+		c.differ(a, b, pointer, field)
+		return
+	}
+	switch a.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			if a.IsNil() != b.IsNil() {
+				c.differ(a, b, pointer, field)
+			}
+			return
+		}
+		if a.Kind() == reflect.Ptr {
+			if a.Pointer() == b.Pointer() {
+				return
+			}
+			key := comparison{a.Pointer(), b.Pointer(), a.Type()}
+			if c.seen[key] {
+				return
+			}
+			c.seen[key] = true
+		}
+		c.compare(a.Elem(), b.Elem(), pointer, field)
+
+	case reflect.Struct:
+		t := a.Type()
+		for i := 0; i < t.NumField() && !c.done(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && c.opts.IgnoreUnexported {
+				continue
+			}
+			c.compare(a.Field(i), b.Field(i), pointer+"/"+escaper.Replace(jsonName(f)), field+"."+f.Name)
+		}
+
+	case reflect.Slice, reflect.Array:
+		if a.Kind() == reflect.Slice {
+			if c.empty(a, b, pointer, field) {
+				return
+			}
+			if a.Pointer() == b.Pointer() && a.Len() == b.Len() {
+				return
+			}
+			key := comparison{a.Pointer(), b.Pointer(), a.Type()}
+			if c.seen[key] {
+				return
+			}
+			c.seen[key] = true
+		}
+		n := a.Len()
+		if b.Len() < n {
+			n = b.Len()
+		}
+		for i := 0; i < n && !c.done(); i++ {
+			c.compare(a.Index(i), b.Index(i), pointer+"/"+strconv.Itoa(i), field+"["+strconv.Itoa(i)+"]")
+		}
+		for i := n; i < a.Len() && !c.done(); i++ {
+			c.differ(a.Index(i), reflect.Value{}, pointer+"/"+strconv.Itoa(i), field+"["+strconv.Itoa(i)+"]")
+		}
+		for i := n; i < b.Len() && !c.done(); i++ {
+			c.differ(reflect.Value{}, b.Index(i), pointer+"/"+strconv.Itoa(i), field+"["+strconv.Itoa(i)+"]")
+		}
+
+	case reflect.Map:
+		if c.empty(a, b, pointer, field) || a.Pointer() == b.Pointer() {
+			return
+		}
+		key := comparison{a.Pointer(), b.Pointer(), a.Type()}
+		if c.seen[key] {
+			return
+		}
+		c.seen[key] = true
+		for _, k := range keys(a, b) {
+			if c.done() {
+				return
+			}
+			name := fmt.Sprint(valueOf(k))
+			c.compare(a.MapIndex(k), b.MapIndex(k), pointer+"/"+escaper.Replace(name), field+"["+strconv.Quote(name)+"]")
+		}
+
+	case reflect.Func:
+		// functions are only equal if both are nil
+		if !a.IsNil() || !b.IsNil() {
+			c.differ(a, b, pointer, field)
+		}
+
+	case reflect.Bool:
+		if a.Bool() != b.Bool() {
+			c.differ(a, b, pointer, field)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if a.Int() != b.Int() {
+			c.differ(a, b, pointer, field)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if a.Uint() != b.Uint() {
+			c.differ(a, b, pointer, field)
+		}
+	case reflect.Float32, reflect.Float64:
+		if a.Float() != b.Float() {
+			c.differ(a, b, pointer, field)
+		}
+	case reflect.Complex64, reflect.Complex128:
+		if a.Complex() != b.Complex() {
+			c.differ(a, b, pointer, field)
+		}
+	case reflect.String:
+		if a.String() != b.String() {
+			c.differ(a, b, pointer, field)
+		}
+	case reflect.Chan, reflect.UnsafePointer:
+		if a.Pointer() != b.Pointer() {
+			c.differ(a, b, pointer, field)
+		}
+	}
+}
+
+// empty compares the slices or maps a and b if both are empty and reports
+// whether they were.
+func (c *comparer) empty(a, b reflect.Value, pointer, field string) bool {
+	if a.Len() != 0 || b.Len() != 0 {
+		return false
+	}
+	if !c.opts.NilEqualsEmpty && a.IsNil() != b.IsNil() {
+		c.differ(a, b, pointer, field)
+	}
+	return true
+}
+
+// keys returns the keys of the maps a and b in a stable order.
+func keys(a, b reflect.Value) []reflect.Value {
+	var all []reflect.Value
+	for _, k := range a.MapKeys() {
+		all = append(all, k)
+	}
+	for _, k := range b.MapKeys() {
+		if !a.MapIndex(k).IsValid() {
+			all = append(all, k)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool {
+		return fmt.Sprint(valueOf(all[i])) < fmt.Sprint(valueOf(all[j]))
+	})
+	return all
+}
+
+// jsonName returns the name of the field f in JSON.
+func jsonName(f reflect.StructField) string {
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
+
+// valueOf returns the value held by v, or its description if it cannot be
+// obtained because v was reached through unexported fields.
+func valueOf(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if !v.CanInterface() {
+		return fmt.Sprint(v)
+	}
+	return v.Interface()
+}
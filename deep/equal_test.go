@@ -0,0 +1,113 @@
+package deep
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+type address struct {
+	City string `json:"city"`
+}
+
+type customer struct {
+	Name    string
+	Address *address `json:"address"`
+	Tags    []string
+	Meta    map[string]interface{}
+	secret  int
+}
+
+func TestEqual(t *testing.T) {
+	values := []interface{}{
+		nil,
+		1,
+		"a",
+		[]int(nil),
+		[]int{},
+		[]int{1, 2},
+		map[string]int{"a": 1},
+		map[string]int{},
+		customer{Name: "a", Tags: []string{"x"}},
+		customer{Name: "a", Tags: []string{"x"}, secret: 1},
+		&customer{Address: &address{City: "Berlin"}},
+		&customer{Address: &address{City: "Amsterdam"}},
+		[]interface{}{1.0, "a", nil},
+		math.NaN(),
+		func() {},
+	}
+	for _, a := range values {
+		for _, b := range values {
+			if Equal(a, b) != reflect.DeepEqual(a, b) {
+				t.Errorf("Equal(%#v, %#v) = %v", a, b, Equal(a, b))
+			}
+		}
+	}
+
+	// cycles are compared without recursing forever
+	x, y := &node{Value: 1}, &node{Value: 1}
+	x.Next, y.Next = x, y
+	if !Equal(x, y) {
+		t.Fatal("equal cycles are not equal")
+	}
+}
+
+func TestDifferences(t *testing.T) {
+	a := customer{
+		Name:    "Calvin",
+		Address: &address{City: "Berlin"},
+		Tags:    []string{"x", "y"},
+		Meta:    map[string]interface{}{"age": 6.0},
+		secret:  1,
+	}
+	b := customer{
+		Name:    "Calvin",
+		Address: &address{City: "Amsterdam"},
+		Tags:    []string{"x"},
+		Meta:    map[string]interface{}{"age": 7.0, "pet": "tiger"},
+		secret:  2,
+	}
+	expected := []Difference{
+		{"/address/city", "customer.Address.City", "Berlin", "Amsterdam"},
+		{"/Tags/1", "customer.Tags[1]", "y", nil},
+		{"/Meta/age", `customer.Meta["age"]`, 6.0, 7.0},
+		{"/Meta/pet", `customer.Meta["pet"]`, nil, "tiger"},
+		{"/secret", "customer.secret", "1", "2"},
+	}
+	d := Differences(a, b)
+	if !reflect.DeepEqual(d, expected) {
+		t.Fatalf("expected %v, got %v", expected, d)
+	}
+	d = DifferencesWithOptions(a, b, &CompareOptions{IgnoreUnexported: true})
+	if !reflect.DeepEqual(d, expected[:4]) {
+		t.Fatalf("expected %v, got %v", expected[:4], d)
+	}
+
+	a = customer{Tags: []string{}}
+	b = customer{Meta: map[string]interface{}{}}
+	if Equal(a, b) || len(Differences(a, b)) != 2 {
+		t.Fatal("nil and empty collections are equal", Differences(a, b))
+	}
+	if !EqualWithOptions(a, b, &CompareOptions{NilEqualsEmpty: true}) {
+		t.Fatal("nil and empty collections are not equal", DifferencesWithOptions(a, b, &CompareOptions{NilEqualsEmpty: true}))
+	}
+}
+
+func TestDifferencesEscaping(t *testing.T) {
+	a := map[string]int{"a/b": 1, "m~n": 2}
+	b := map[string]int{"a/b": 2, "m~n": 3}
+	d := Differences(a, b)
+	if len(d) != 2 || d[0].Pointer != "/a~1b" || d[1].Pointer != "/m~0n" {
+		t.Fatal("unexpected differences", d)
+	}
+}
+
+func TestEqualCycles(t *testing.T) {
+	a := []interface{}{1, nil}
+	a[1] = a
+	b := []interface{}{1, nil}
+	b[1] = b
+	if !Equal(a, b) {
+		t.Fatal("equal cyclic slices differ", Differences(a, b))
+	}
+}
@@ -0,0 +1,90 @@
+package deep
+
+import (
+	"context"
+	"reflect"
+)
+
+// CopyFunc returns a copy of x, which is of the type it is registered for.
+type CopyFunc func(x interface{}) (interface{}, error)
+
+// Options configures CopyWithOptions.
+type Options struct {
+	// Unexported enables copying unexported struct fields deeply. By
+	// default they are copied shallowly, so the copy shares the values
+	// they point to with the original.
+	Unexported bool
+
+	// Copiers holds the functions copying values of the types they are
+	// registered for, instead of copying them recursively.
+	Copiers map[reflect.Type]CopyFunc
+	// Shallow lists the types which are copied by assignment, e.g. types
+	// holding locks or handles such as *sql.DB.
+	Shallow []reflect.Type
+	// SkipUnsupported makes channels and functions be copied by
+	// assignment instead of failing with ErrUnsupported.
+	SkipUnsupported bool
+}
+
+// CopyWithOptions makes a recursive deep copy of obj x to y in the same way
+// as Copy, configured by opts. opts may be nil.
+//
+// Besides using the copiers set in opts, values whose type has a method
+// DeepCopy or Clone without arguments returning a value of the same type,
+// and optionally an error, are copied by calling the method. The method is
+// not used for x itself, so it can be implemented using CopyWithOptions.
+func CopyWithOptions(x, y interface{}, opts *Options) error {
+	return copyWith(context.Background(), x, y, opts)
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+func newCopier(ctx context.Context, opts *Options) *copier {
+	c := &copier{ctx: ctx, seen: map[visit]reflect.Value{}, root: true, fast: opts == nil}
+	if opts != nil {
+		c.unexported = opts.Unexported
+		c.opts = opts
+	}
+	return c
+}
+
+// custom returns the copy of x if it is copied in any other way than
+// recursively. The second return value is false if it is not.
+func (c *copier) custom(x reflect.Value, root bool) (reflect.Value, bool, error) {
+	t := x.Type()
+	for _, s := range c.opts.Shallow {
+		if s == t {
+			return x, true, nil
+		}
+	}
+	if fn, ok := c.opts.Copiers[t]; ok {
+		v, err := fn(x.Interface())
+		if err != nil {
+			return reflect.Value{}, true, err
+		}
+		if v == nil {
+			return reflect.Zero(t), true, nil
+		}
+		return reflect.ValueOf(v), true, nil
+	}
+	if root {
+		return reflect.Value{}, false, nil
+	}
+	for _, name := range []string{"DeepCopy", "Clone"} {
+		m, ok := t.MethodByName(name)
+		if !ok || m.Type.NumIn() != 1 || m.Type.NumOut() == 0 || m.Type.Out(0) != t {
+			continue
+		}
+		switch {
+		case m.Type.NumOut() == 1:
+			return m.Func.Call([]reflect.Value{x})[0], true, nil
+		case m.Type.NumOut() == 2 && m.Type.Out(1) == errorType:
+			out := m.Func.Call([]reflect.Value{x})
+			if !out[1].IsNil() {
+				return reflect.Value{}, true, out[1].Interface().(error)
+			}
+			return out[0], true, nil
+		}
+	}
+	return reflect.Value{}, false, nil
+}
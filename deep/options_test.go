@@ -0,0 +1,110 @@
+package deep
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+type handle struct {
+	Name string
+}
+
+type buffer struct {
+	Data []byte
+}
+
+func (b buffer) Clone() buffer {
+	return buffer{Data: append([]byte("cloned:"), b.Data...)}
+}
+
+type version struct {
+	N int
+}
+
+func (v *version) DeepCopy() *version {
+	return &version{N: v.N + 1}
+}
+
+type checked struct {
+	Err error
+}
+
+func (c *checked) DeepCopy() (*checked, error) {
+	return nil, c.Err
+}
+
+type service struct {
+	Mu       *sync.Mutex
+	DB       *handle
+	Buf      buffer
+	Version  *version
+	Handlers map[string]func() string
+	Done     chan struct{}
+	Limits   []int
+}
+
+func TestCopyWithOptions(t *testing.T) {
+	x := service{
+		Mu:       &sync.Mutex{},
+		DB:       &handle{Name: "db"},
+		Buf:      buffer{Data: []byte("a")},
+		Version:  &version{N: 1},
+		Handlers: map[string]func() string{"a": func() string { return "a" }},
+		Done:     make(chan struct{}),
+		Limits:   []int{1, 2},
+	}
+	err := Copy(&x, &service{})
+	if err != ErrUnsupported {
+		t.Fatal("expected ErrUnsupported, got", err)
+	}
+
+	var y service
+	opts := &Options{
+		Shallow: []reflect.Type{reflect.TypeOf(&sync.Mutex{}), reflect.TypeOf(&handle{})},
+		Copiers: map[reflect.Type]CopyFunc{
+			reflect.TypeOf([]int{}): func(x interface{}) (interface{}, error) {
+				return []int{len(x.([]int))}, nil
+			},
+		},
+		SkipUnsupported: true,
+	}
+	err = CopyWithOptions(&x, &y, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y.Mu != x.Mu || y.DB != x.DB {
+		t.Fatal("shallow types were copied")
+	}
+	if string(y.Buf.Data) != "cloned:a" || y.Version.N != 2 {
+		t.Fatal("Clone and DeepCopy methods were not used", y)
+	}
+	if y.Handlers["a"]() != "a" || y.Done != x.Done {
+		t.Fatal("functions and channels were not copied by assignment", y)
+	}
+	if !reflect.DeepEqual(y.Limits, []int{2}) {
+		t.Fatal("custom copier was not used", y.Limits)
+	}
+
+	// the method of the value being copied is not used
+	var b buffer
+	err = CopyWithOptions(&x.Buf, &b, nil)
+	if err != nil || string(b.Data) != "a" {
+		t.Fatal("unexpected copy", b, err)
+	}
+
+	failing := errors.New("failed")
+	opts.Copiers[reflect.TypeOf([]int{})] = func(x interface{}) (interface{}, error) {
+		return nil, failing
+	}
+	err = CopyWithOptions(&x, &y, opts)
+	if err != failing {
+		t.Fatal("expected the error of the copier, got", err)
+	}
+
+	err = CopyWithOptions(&struct{ C *checked }{&checked{failing}}, &struct{ C *checked }{}, &Options{})
+	if err != failing {
+		t.Fatal("expected the error of DeepCopy, got", err)
+	}
+}
@@ -0,0 +1,102 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/optiopay/jsonpatch/deep"
+)
+
+// Diff returns a patch which turns the JSON encoding of a into the one of b.
+//
+// Objects are compared member by member. Arrays are compared element by
+// element, elements missing in a are added and the ones missing in b are
+// removed at the end of the array. Any other differing value is replaced.
+func Diff(a, b interface{}) ([]byte, error) {
+	x, err := generic(a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := generic(b)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(diff([]Patch{}, "", x, y))
+}
+
+// generic returns the value JSON decodes the encoding of x into.
+func generic(x interface{}) (interface{}, error) {
+	data, err := json.Marshal(x)
+	if err != nil {
+		return nil, err
+	}
+	var v interface{}
+	err = json.Unmarshal(data, &v)
+	return v, err
+}
+
+// diff appends the operations turning x into y at path to ops.
+func diff(ops []Patch, path string, x, y interface{}) []Patch {
+	switch x := x.(type) {
+	case map[string]interface{}:
+		y, ok := y.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(x)+len(y))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		for k := range y {
+			if _, ok := x[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			vx, inX := x[k]
+			vy, inY := y[k]
+			child := path + "/" + escape(k)
+			switch {
+			case !inY:
+				ops = append(ops, Patch{Op: "remove", Path: child})
+			case !inX:
+				ops = append(ops, Patch{Op: "add", Path: child, Value: marshal(vy)})
+			default:
+				ops = diff(ops, child, vx, vy)
+			}
+		}
+		return ops
+
+	case []interface{}:
+		y, ok := y.([]interface{})
+		if !ok {
+			break
+		}
+		n := len(x)
+		if len(y) < n {
+			n = len(y)
+		}
+		for i := 0; i < n; i++ {
+			ops = diff(ops, path+"/"+strconv.Itoa(i), x[i], y[i])
+		}
+		for i := len(x) - 1; i >= n; i-- {
+			ops = append(ops, Patch{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+		}
+		for i := n; i < len(y); i++ {
+			ops = append(ops, Patch{Op: "add", Path: path + "/" + strconv.Itoa(i), Value: marshal(y[i])})
+		}
+		return ops
+	}
+	if deep.Equal(x, y) {
+		return ops
+	}
+	return append(ops, Patch{Op: "replace", Path: path, Value: marshal(y)})
+}
+
+func marshal(v interface{}) json.RawMessage {
+	// values decoded from JSON can always be encoded
+	data, _ := json.Marshal(v)
+	return data
+}
@@ -0,0 +1,162 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	a := testUser{Name: "hobbes", Age: 6, Phones: []string{"1", "2", "3"}, M: map[string]string{"a": "1"}}
+	b := testUser{Name: "Calvin", Age: 6, Phones: []string{"1", "4"}, M: map[string]string{"b": "2"}, Child: &testUser{Name: "Susie"}}
+	data, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := a
+	err = Apply(data, &u)
+	if err != nil {
+		t.Fatal(string(data), err)
+	}
+	if !reflect.DeepEqual(u, b) {
+		t.Fatalf("%s\nresulted in %+v\nexpected %+v", data, u, b)
+	}
+
+	data, err = Diff(a, a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[]" {
+		t.Fatal("expected an empty patch, got", string(data))
+	}
+}
+
+func TestDiffEscaping(t *testing.T) {
+	a := map[string]interface{}{"a/b": 1.0, "m~n": map[string]interface{}{"x/y": 1.0}}
+	b := map[string]interface{}{"a/b": 2.0, "m~n": map[string]interface{}{"x/y": 2.0}}
+	data, err := Diff(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `[{"op":"replace","path":"/a~1b","value":2},{"op":"replace","path":"/m~0n/x~1y","value":2}]`
+	if string(data) != expected {
+		t.Fatalf("expected %s, got %s", expected, data)
+	}
+	err = Apply(data, &a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, b) {
+		t.Fatal("unexpected result", a)
+	}
+}
+
+func TestDiffRoundTrip(t *testing.T) {
+	tests := []struct {
+		a, b string
+	}{
+		{`{"a": 1}`, `[1]`},
+		{`1`, `2`},
+		{`{"a": 1}`, `null`},
+		{`{"a": {"": 1}}`, `{"a": {"": 2}}`},
+		{`{"": {"": 1}}`, `{"": {"": [2]}}`},
+		{`{"a": 5}`, `{"a": {"b": 1}}`},
+	}
+	for _, test := range tests {
+		var a, b interface{}
+		json.Unmarshal([]byte(test.a), &a)
+		json.Unmarshal([]byte(test.b), &b)
+		data, err := Diff(a, b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = Apply(data, &a)
+		if err != nil {
+			t.Errorf("%s -> %s: %s failed: %v", test.a, test.b, data, err)
+			continue
+		}
+		if !reflect.DeepEqual(a, b) {
+			t.Errorf("%s -> %s: %s resulted in %v", test.a, test.b, data, a)
+		}
+	}
+}
+
+func TestApplyPointers(t *testing.T) {
+	var doc interface{} = map[string]interface{}{"a": 5.0, "": map[string]interface{}{"": 1.0}}
+	failing := map[string]error{
+		`{"op": "add", "path": "/a/b", "value": 1}`:     ErrNodeNil,
+		`{"op": "replace", "path": "/a/b", "value": 1}`: ErrNodeNil,
+		`{"op": "remove", "path": "/a/b"}`:              ErrNodeNil,
+		`{"op": "test", "path": "", "value": 1}`:        ErrTestFailed,
+	}
+	for op, expected := range failing {
+		err := Apply([]byte("["+op+"]"), &doc)
+		if err != expected {
+			t.Errorf("%s: expected %v, got %v", op, expected, err)
+		}
+	}
+	err := Apply([]byte(`[{"op": "replace", "path": "a", "value": 1}]`), &doc)
+	if _, ok := err.(*ErrInvalidPointer); !ok {
+		t.Error("expected ErrInvalidPointer, got", err)
+	}
+
+	p := []byte(`[
+		{"op": "replace", "path": "//", "value": 2},
+		{"op": "test", "path": "", "value": {"a": 5, "": {"": 2}}},
+		{"op": "copy", "from": "", "path": "/b"},
+		{"op": "move", "from": "/b", "path": ""}
+	]`)
+	err = Apply(p, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]interface{}{"a": 5.0, "": map[string]interface{}{"": 2.0}}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Fatal("unexpected result", doc)
+	}
+	err = Apply([]byte(`[{"op": "remove", "path": ""}]`), &doc)
+	if err != nil || doc != nil {
+		t.Fatal("unexpected result", doc, err)
+	}
+}
+
+func TestApplyUntyped(t *testing.T) {
+	var doc interface{}
+	err := json.Unmarshal([]byte(`{"name": "hobbes", "tags": ["a"], "address": {"city": "Berlin"}}`), &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orig, _ := json.Marshal(doc)
+	p := []byte(`[
+		{"op": "replace", "path": "/address/city", "value": "Amsterdam"},
+		{"op": "add", "path": "/tags/-", "value": {"b": 1}},
+		{"op": "test", "path": "/tags/1/b", "value": 1},
+		{"op": "remove", "path": "/name"}
+	]`)
+	err = Apply(p, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(doc)
+	expected := `{"address":{"city":"Amsterdam"},"tags":["a",{"b":1}]}`
+	if string(data) != expected {
+		t.Fatalf("expected %s, got %s", expected, data)
+	}
+
+	err = json.Unmarshal(orig, &doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p = []byte(`[
+		{"op": "replace", "path": "/address/city", "value": "Amsterdam"},
+		{"op": "test", "path": "/name", "value": "Calvin"}
+	]`)
+	err = Apply(p, &doc)
+	if err != ErrTestFailed {
+		t.Fatal("expected ErrTestFailed, got", err)
+	}
+	data, _ = json.Marshal(doc)
+	if string(data) != string(orig) {
+		t.Fatal("failed patch modified the document", string(data))
+	}
+}
@@ -2,6 +2,7 @@
 package jsonpatch
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -24,6 +25,8 @@ var (
 	ErrNodeNil        = errors.New("jsonpatch: node was empty")
 	ErrIncorrectIndex = errors.New("jsonpatch: incorrect index")
 	ErrNotImplemented = errors.New("jsonpatch: not implemented")
+	ErrAmbiguous      = errors.New("jsonpatch: order of concurrent operations is ambiguous")
+	ErrTestFailed     = errors.New("jsonpatch: test failed, elements are not equal")
 )
 
 type ErrUnsupported struct {
@@ -34,19 +37,65 @@ func (e *ErrUnsupported) Error() string {
 	return fmt.Sprintf("jsonpatch: unsupported type for key %s", e.Err)
 }
 
+// ErrValidation is returned when the patched value does not pass the
+// validation.
+type ErrValidation struct {
+	Err error
+}
+
+func (e *ErrValidation) Error() string {
+	return fmt.Sprintf("jsonpatch: validation failed: %v", e.Err)
+}
+
+func (e *ErrValidation) Unwrap() error {
+	return e.Err
+}
+
+// Validator is implemented by types which check their own invariants. The
+// result of a patch is discarded if its Validate method returns an error.
+type Validator interface {
+	Validate() error
+}
+
+// Patchable is implemented by types which apply patch operations to
+// themselves without reflection, such as the types annotated for the
+// jsonpatch-gen command. Apply delegates every operation to its ApplyOp
+// method, and applies the operation through reflection when it returns
+// ErrNotImplemented, which it must do without having modified the value.
+type Patchable interface {
+	ApplyOp(p *Patch) error
+}
+
 // Patch represents an individual patch operation
 type Patch struct {
-	Op    string
-	Path  string
-	From  string
-	Value json.RawMessage
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
 }
 
 // Apply applies a patch as defined in RFC 6902 to the passed interface.
+// Paths are parsed as defined in RFC 6901, the empty path referring to the
+// whole value. Removing the whole value sets it to its zero value.
 //
 // Apply makes a deep copy of the entire structure. Thus patches on large
-// data structures will not be efficient.
+// data structures will not be efficient. If x implements Validator, the
+// patched copy is validated before it replaces the original. If it
+// implements Patchable, the operations are delegated to it.
 func Apply(data []byte, x interface{}) error {
+	return apply(context.Background(), data, x, nil, nil)
+}
+
+// ApplyContext applies a patch to x in the same way as Apply. It stops
+// applying the patch and returns ctx.Err() once ctx is done, in which case
+// x is left untouched.
+func ApplyContext(ctx context.Context, data []byte, x interface{}) error {
+	return apply(ctx, data, x, nil, nil)
+}
+
+// apply implements Apply. When t is not nil it is notified before and
+// after each of the operations.
+func apply(ctx context.Context, data []byte, x interface{}, opts *ApplyOptions, t *tracker) error {
 	rx := reflect.ValueOf(x)
 	if rx.Kind() != reflect.Ptr || rx.IsNil() {
 		return ErrNonPointer
@@ -58,33 +107,285 @@ func Apply(data []byte, x interface{}) error {
 		return err
 	}
 
-	ry := reflect.New(rx.Elem().Type())
+	if opts != nil {
+		err = opts.check(patches, rx.Elem().Type())
+		if err != nil {
+			return err
+		}
+		err = opts.resolve(patches, rx.Elem().Type())
+		if err != nil {
+			return err
+		}
+		if opts.AfterOp != nil {
+			if t == nil {
+				t = &tracker{}
+			}
+			t.hook = opts.AfterOp
+		}
+	}
+
 	// I am making a copy of the interface so that when an
 	// error arises while performing one of the patches the
 	// original data structure does not get altered.
-	err = deep.Copy(x, ry.Interface())
+	ry, err := copyOf(ctx, rx)
 	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
 		return ErrCouldNotCopy
 	}
 
+	var size int
+	if opts != nil {
+		size, err = opts.documentSize(ry)
+		if err != nil {
+			return err
+		}
+	}
 	for _, p := range patches {
-		path := strings.Trim(p.Path, "/")
-		err := rapply(path, &p, ry)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		path, _, err := parsePointer(p.Path)
 		if err != nil {
 			return err
 		}
+		if opts != nil && opts.BeforeOp != nil {
+			err = opts.BeforeOp(p)
+			if err != nil {
+				return err
+			}
+		}
+		if t != nil {
+			err = t.before(path, &p, ry)
+			if err != nil {
+				return err
+			}
+		}
+		err = applyOp(path, &p, ry)
+		if err != nil {
+			if t != nil {
+				t.fail(err)
+			}
+			return err
+		}
+		if opts != nil {
+			err = opts.checkLength(path, ry)
+			if err != nil {
+				return err
+			}
+			size, err = opts.checkGrowth(size, &p, ry)
+			if err != nil {
+				return err
+			}
+		}
+		if t != nil {
+			err = t.after(path, &p, ry)
+			if err != nil {
+				return err
+			}
+		}
 	}
 
+	if opts != nil {
+		err = opts.checkDocument(ry)
+		if err != nil {
+			return err
+		}
+		if opts.Validate != nil {
+			err = opts.Validate(ry.Interface())
+			if err != nil {
+				return &ErrValidation{err}
+			}
+		}
+	}
+	if v, ok := ry.Interface().(Validator); ok {
+		err = v.Validate()
+		if err != nil {
+			return &ErrValidation{err}
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if opts != nil && opts.DryRun || t != nil && t.explain {
+		return nil
+	}
 	rx.Elem().Set(ry.Elem())
 	return nil
 }
 
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// copyOf returns a deep copy of the pointer x. Types with a DeepCopy method
+// returning the same type, like the ones generated by jsonpatch-gen, are
+// copied by calling it, all others through reflection.
+func copyOf(ctx context.Context, x reflect.Value) (reflect.Value, error) {
+	m := x.MethodByName("DeepCopy")
+	if m.IsValid() {
+		t := m.Type()
+		if t.NumIn() == 0 && t.NumOut() > 0 && t.Out(0) == x.Type() &&
+			(t.NumOut() == 1 || t.NumOut() == 2 && t.Out(1) == errorType) {
+			out := m.Call(nil)
+			if len(out) == 2 && !out[1].IsNil() {
+				return reflect.Value{}, out[1].Interface().(error)
+			}
+			if !out[0].IsNil() {
+				return out[0], nil
+			}
+		}
+	}
+	y := reflect.New(x.Elem().Type())
+	err := deep.CopyContext(ctx, x.Interface(), y.Interface())
+	return y, err
+}
+
+// tracker records the effect of the operations performed by apply.
+type tracker struct {
+	// invert enables collecting the inverse patch.
+	invert  bool
+	inverse []Patch
+	undo    []Patch
+
+	// report enables collecting the changes.
+	report  bool
+	changes []Change
+	pending []Change
+
+	// hook is called with the change made by each of the operations.
+	hook func(Change) error
+
+	// explain enables collecting the explanations. The patched copy is
+	// then never stored in x.
+	explain      bool
+	explanations []Explanation
+}
+
+// describes reports whether t needs the change made by p.
+func (t *tracker) describes(p *Patch) bool {
+	return (t.report && p.Op != "test") || t.hook != nil || t.explain
+}
+
+func (t *tracker) before(path string, p *Patch, x reflect.Value) error {
+	var err error
+	if t.invert {
+		t.undo, err = invert(path, p, x)
+		if err != nil {
+			return err
+		}
+	}
+	if t.describes(p) {
+		t.pending, err = describe(path, p, x)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *tracker) after(path string, p *Patch, x reflect.Value) error {
+	if t.invert {
+		t.inverse = append(t.undo, t.inverse...)
+	}
+	if !t.describes(p) {
+		return nil
+	}
+	for i := range t.pending {
+		c := &t.pending[i]
+		// the first change of a move is the one of its source
+		removed := p.Op == "remove" || (p.Op == "move" && i == 0)
+		err := c.update(x, removed)
+		if err != nil {
+			return err
+		}
+		if t.report && p.Op != "test" {
+			t.changes = append(t.changes, *c)
+		}
+		if t.explain {
+			t.explanations = append(t.explanations, Explanation{Change: *c})
+		}
+		if t.hook != nil {
+			err = t.hook(*c)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// fail is called when applying an operation failed with err.
+func (t *tracker) fail(err error) {
+	if t.explain {
+		c := t.pending[len(t.pending)-1]
+		t.explanations = append(t.explanations, Explanation{Change: c, Err: err})
+	}
+}
+
+// applyOp applies p to the pointer x, delegating it to x if it implements
+// Patchable.
+func applyOp(path string, p *Patch, x reflect.Value) error {
+	if px, ok := x.Interface().(Patchable); ok {
+		err := px.ApplyOp(p)
+		if err != ErrNotImplemented {
+			return err
+		}
+	}
+	if p.Path == "" {
+		return applyRoot(p, x)
+	}
+	if p.Op == "copy" || p.Op == "move" {
+		return transfer(path, p, x)
+	}
+	return rapply(path, p, x)
+}
+
+// applyRoot applies p to the whole value the pointer x points to. Removing
+// it sets it to its zero value.
+func applyRoot(p *Patch, x reflect.Value) error {
+	value := p.Value
+	switch p.Op {
+	case "add", "replace":
+	case "remove":
+		x.Elem().Set(reflect.Zero(x.Elem().Type()))
+		return nil
+	case "test":
+		return equalJSON(x.Elem(), p.Value)
+	case "copy", "move":
+		_, nodes, err := parsePointer(p.From)
+		if err != nil {
+			return err
+		}
+		v, err := resolveNodes(nodes, x)
+		if err != nil || !v.CanInterface() {
+			return ErrNodeNil
+		}
+		value, err = json.Marshal(v.Interface())
+		if err != nil {
+			return err
+		}
+	default:
+		if operation(p.Op) == nil {
+			return &ErrUnknownOp{p.Op}
+		}
+		// custom operations need the container of the location
+		return &ErrUnsupported{p.Path}
+	}
+	v := reflect.New(x.Elem().Type())
+	err := json.Unmarshal(value, v.Interface())
+	if err != nil {
+		return err
+	}
+	x.Elem().Set(v.Elem())
+	return nil
+}
+
 func rapply(path string, p *Patch, x reflect.Value) error {
 	args := strings.SplitN(path, "/", 2)
 	if len(args) == 2 {
-		return findNode(args[0], args[1], p, x)
+		return findNode(unescape(args[0]), args[1], p, x)
 	}
-	return applyNode(args[0], p, x)
+	return applyNode(unescape(args[0]), p, x)
 }
 
 func findNode(root, node string, p *Patch, x reflect.Value) error {
@@ -96,6 +397,14 @@ func findNode(root, node string, p *Patch, x reflect.Value) error {
 		}
 		x = x.Elem()
 	}
+	if c := containerOf(x); c != nil {
+		return findIn(c, root, node, p)
+	}
+	if x.Kind() == reflect.Interface {
+		return viaInterface(x, func(v reflect.Value) error {
+			return findNode(root, node, p, v)
+		})
+	}
 	switch x.Kind() {
 	case reflect.Slice, reflect.Array:
 		pos, err := strconv.Atoi(root)
@@ -107,7 +416,21 @@ func findNode(root, node string, p *Patch, x reflect.Value) error {
 		}
 		child = x.Index(pos)
 	case reflect.Map:
-		child = x.MapIndex(reflect.ValueOf(root))
+		key := reflect.ValueOf(root)
+		child = x.MapIndex(key)
+		if !child.IsValid() {
+			return ErrNodeNil
+		}
+		// map elements are not addressable, the child is modified in a
+		// copy which is stored back afterwards
+		c := reflect.New(child.Type())
+		c.Elem().Set(child)
+		err := rapply(node, p, c)
+		if err != nil {
+			return err
+		}
+		x.SetMapIndex(key, c.Elem())
+		return nil
 	case reflect.Struct:
 		t := x.Type()
 		name := bestMatch(root, t)
@@ -134,13 +457,6 @@ func findNode(root, node string, p *Patch, x reflect.Value) error {
 		return rapply(node, p, child)
 	}
 
-	// Case when the value is a zero value
-	if !child.IsValid() {
-		newval := reflect.New(child.Type().Elem())
-		//newval returns a pointer to the element.
-		child.Set(newval.Elem())
-	}
-
 	if child.CanAddr() {
 		return rapply(node, p, child.Addr())
 	}
@@ -148,22 +464,190 @@ func findNode(root, node string, p *Patch, x reflect.Value) error {
 	return &ErrUnsupported{root}
 }
 
+// viaInterface calls fn with a pointer to a copy of the value held by the
+// interface x and stores the modified copy back in x.
+func viaInterface(x reflect.Value, fn func(v reflect.Value) error) error {
+	if x.IsNil() {
+		return ErrNodeNil
+	}
+	v := reflect.New(x.Elem().Type())
+	v.Elem().Set(x.Elem())
+	err := fn(v)
+	if err != nil {
+		return err
+	}
+	x.Set(v.Elem())
+	return nil
+}
+
+// lookup resolves path against x without modifying it. The second return
+// value is false if any segment of the path does not exist.
+func lookup(path string, x reflect.Value) (reflect.Value, bool) {
+	v, err := walk(path, x)
+	return v, err == nil
+}
+
+// walk resolves path against x without modifying it. It returns
+// ErrNotFound for the first segment of the path which does not exist.
+func walk(path string, x reflect.Value) (reflect.Value, error) {
+	return resolveNodes(nodesOf(path), x)
+}
+
+// resolveNodes resolves the unescaped segments of a path against x in the
+// same way as walk.
+func resolveNodes(nodes []string, x reflect.Value) (reflect.Value, error) {
+	for i, node := range nodes {
+		if c := containerOf(x); c != nil {
+			v, ok := c.Get(node)
+			if !ok {
+				return reflect.Value{}, notFound(nodes, i)
+			}
+			x = reflect.ValueOf(v)
+			continue
+		}
+		x = indirect(x)
+		switch x.Kind() {
+		case reflect.Slice, reflect.Array:
+			pos, err := strconv.Atoi(node)
+			if err != nil || pos < 0 || pos >= x.Len() {
+				return reflect.Value{}, notFound(nodes, i)
+			}
+			x = x.Index(pos)
+		case reflect.Map:
+			if x.Type().Key().Kind() != reflect.String {
+				return reflect.Value{}, notFound(nodes, i)
+			}
+			x = x.MapIndex(reflect.ValueOf(node).Convert(x.Type().Key()))
+			if !x.IsValid() {
+				return x, notFound(nodes, i)
+			}
+		case reflect.Struct:
+			name := bestMatch(node, x.Type())
+			if name == "" {
+				return reflect.Value{}, notFound(nodes, i)
+			}
+			x = x.FieldByName(name)
+		default:
+			return reflect.Value{}, notFound(nodes, i)
+		}
+	}
+	return x, nil
+}
+
+var (
+	escaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	unescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// escape returns the segment escaped for use in a JSON Pointer.
+func escape(segment string) string {
+	return escaper.Replace(segment)
+}
+
+// unescape returns the segment of a JSON Pointer with ~1 and ~0 decoded
+// as defined by RFC 6901.
+func unescape(segment string) string {
+	if !strings.Contains(segment, "~") {
+		return segment
+	}
+	return unescaper.Replace(segment)
+}
+
+// nodesOf returns the unescaped segments of path, a JSON Pointer without its
+// leading slash. The empty path has no segments.
+func nodesOf(path string) []string {
+	if path == "" {
+		return nil
+	}
+	nodes := strings.Split(path, "/")
+	for i, node := range nodes {
+		nodes[i] = unescape(node)
+	}
+	return nodes
+}
+
+// notFound returns the ErrNotFound for the segment i of the path nodes.
+func notFound(nodes []string, i int) error {
+	var pointer strings.Builder
+	for _, node := range nodes[:i+1] {
+		pointer.WriteString("/" + escape(node))
+	}
+	return &ErrNotFound{Pointer: pointer.String(), Segment: nodes[i]}
+}
+
+// parentOf returns the container holding the location path points to, or
+// the zero Value if it does not exist.
+func parentOf(path string, x reflect.Value) reflect.Value {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return x
+	}
+	parent, _ := lookup(path[:i], x)
+	return parent
+}
+
+// resolveEnd replaces the "-" at the end of a path pointing into a slice
+// with the index the next element would be appended at.
+func resolveEnd(path string, x reflect.Value) string {
+	if path != "-" && !strings.HasSuffix(path, "/-") {
+		return path
+	}
+	parent := indirect(parentOf(path, x))
+	if parent.Kind() != reflect.Slice {
+		return path
+	}
+	return path[:len(path)-1] + strconv.Itoa(parent.Len())
+}
+
+// resolveMoveEnd is resolveEnd for the path of a move from from, which
+// removes the value before it is appended.
+func resolveMoveEnd(path, from string, x reflect.Value) string {
+	end := resolveEnd(path, x)
+	if end == path || parentPath(from) != parentPath(path) {
+		return end
+	}
+	parent := indirect(parentOf(path, x))
+	return path[:len(path)-1] + strconv.Itoa(parent.Len()-1)
+}
+
+// parentPath returns the path of the parent of path.
+func parentPath(path string) string {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return ""
+	}
+	return path[:i]
+}
+
+// indirect follows pointers and interfaces until it reaches a concrete
+// value. It returns the zero Value when it encounters nil.
+func indirect(x reflect.Value) reflect.Value {
+	for x.Kind() == reflect.Ptr || x.Kind() == reflect.Interface {
+		if x.IsNil() {
+			return reflect.Value{}
+		}
+		x = x.Elem()
+	}
+	return x
+}
+
 // bestMatch returns the field name of the struct field which is the
-// closest to the name passed.
+// closest to the name passed. The exact name of a field matches before the
+// names given in json and yaml tags of the fields declared ahead of it, so
+// that the paths rewritten by a FieldResolver address the resolved fields.
+// Fields promoted from embedded structs are not matched.
 func bestMatch(name string, t reflect.Type) string {
+	if f, ok := t.FieldByName(name); ok && len(f.Index) == 1 {
+		return name
+	}
 	key := strings.ToLower(hyphens.ReplaceAllString(name, ""))
 	for i := 0; i < t.NumField(); i++ {
 		field := t.Field(i)
-		if field.Name == name {
-			return field.Name
-		}
-		j := field.Tag.Get("json")
-		if j != "" {
-			flags := strings.Split(j, ",")
-			for _, flag := range flags {
-				if name == flag {
-					return field.Name
-				}
+		for _, tag := range []string{"json", "yaml"} {
+			// the options following the name are not names
+			j := strings.Split(field.Tag.Get(tag), ",")[0]
+			if j != "" && name == j {
+				return field.Name
 			}
 		}
 		lname := strings.ToLower(hyphens.ReplaceAllString(field.Name, ""))
@@ -175,6 +659,14 @@ func bestMatch(name string, t reflect.Type) string {
 }
 
 func applyNode(node string, p *Patch, x reflect.Value) error {
+	if c := containerOf(x); c != nil {
+		return applyIn(c, node, p)
+	}
+	if x.Kind() == reflect.Ptr && x.Elem().Kind() == reflect.Interface {
+		return viaInterface(x.Elem(), func(v reflect.Value) error {
+			return applyNode(node, p, v)
+		})
+	}
 	switch p.Op {
 	case "add":
 		return add(node, p, x)
@@ -189,7 +681,11 @@ func applyNode(node string, p *Patch, x reflect.Value) error {
 	case "move":
 		return ErrNotImplemented
 	}
-	return nil
+	fn := operation(p.Op)
+	if fn == nil {
+		return &ErrUnknownOp{p.Op}
+	}
+	return fn(x, node, p)
 }
 
 func add(node string, p *Patch, v reflect.Value) error {
@@ -204,32 +700,22 @@ func add(node string, p *Patch, v reflect.Value) error {
 	switch v.Kind() {
 	case reflect.Slice:
 		l := v.Len()
-		if node == "-" {
-			sl := reflect.MakeSlice(v.Type(), l+1, l+1)
-			reflect.Copy(sl, v)
-			child = sl.Index(l)
-			n := child.Interface()
-			err := json.Unmarshal(p.Value, &n)
-			if err != nil {
-				return err
+		pos := l
+		if node != "-" {
+			var err error
+			pos, err = strconv.Atoi(node)
+			if err != nil || pos < 0 || pos > l {
+				return ErrIncorrectIndex
 			}
-			child.Set(reflect.ValueOf(n))
-			v.Set(sl)
-			return nil
-		}
-		pos, err := strconv.Atoi(node)
-		if err != nil {
-			return ErrIncorrectIndex
 		}
-		child = v.Index(pos)
-		n := child.Interface()
-		err = json.Unmarshal(p.Value, &n)
+		child = reflect.New(v.Type().Elem())
+		err := json.Unmarshal(p.Value, child.Interface())
 		if err != nil {
 			return err
 		}
 		sl := reflect.MakeSlice(v.Type(), 0, l+1)
 		sl = reflect.AppendSlice(sl, v.Slice(0, pos))
-		sl = reflect.Append(sl, reflect.ValueOf(n))
+		sl = reflect.Append(sl, child.Elem())
 		sl = reflect.AppendSlice(sl, v.Slice(pos, l))
 		v.Set(sl)
 
@@ -237,12 +723,12 @@ func add(node string, p *Patch, v reflect.Value) error {
 		if v.IsNil() {
 			v.Set(reflect.MakeMap(v.Type()))
 		}
-		n := reflect.Zero(v.Type().Elem()).Interface()
-		err := json.Unmarshal(p.Value, &n)
+		n := reflect.New(v.Type().Elem())
+		err := json.Unmarshal(p.Value, n.Interface())
 		if err != nil {
 			return err
 		}
-		v.SetMapIndex(reflect.ValueOf(node), reflect.ValueOf(n))
+		v.SetMapIndex(reflect.ValueOf(node), n.Elem())
 
 	case reflect.Struct:
 		name := bestMatch(node, v.Type())
@@ -277,6 +763,9 @@ func add(node string, p *Patch, v reflect.Value) error {
 			return err
 		}
 		v.Set(reflect.ValueOf(el).Addr())
+	default:
+		// primitive types cannot have children
+		return ErrNodeNil
 	}
 	return nil
 }
@@ -292,16 +781,16 @@ func replace(node string, p *Patch, v reflect.Value) error {
 		if err != nil {
 			return ErrIncorrectIndex
 		}
-		if pos > v.Len() {
+		if pos < 0 || pos >= v.Len() {
 			return ErrIncorrectIndex
 		}
 		child = v.Index(pos)
-		n := child.Interface()
-		err = json.Unmarshal(p.Value, &n)
+		n := reflect.New(child.Type())
+		err = json.Unmarshal(p.Value, n.Interface())
 		if err != nil {
 			return err
 		}
-		child.Set(reflect.ValueOf(n))
+		child.Set(n.Elem())
 		return nil
 
 	case reflect.Map:
@@ -309,12 +798,12 @@ func replace(node string, p *Patch, v reflect.Value) error {
 		if !child.IsValid() {
 			return errors.New("map element not found")
 		}
-		n := child.Interface()
-		err := json.Unmarshal(p.Value, &n)
+		n := reflect.New(child.Type())
+		err := json.Unmarshal(p.Value, n.Interface())
 		if err != nil {
 			return err
 		}
-		v.SetMapIndex(reflect.ValueOf(node), reflect.ValueOf(n))
+		v.SetMapIndex(reflect.ValueOf(node), n.Elem())
 		return nil
 
 	case reflect.Struct:
@@ -336,7 +825,8 @@ func replace(node string, p *Patch, v reflect.Value) error {
 		return ErrNotImplemented
 
 	}
-	return nil
+	// primitive types cannot have children
+	return ErrNodeNil
 }
 
 func remove(node string, p *Patch, v reflect.Value) error {
@@ -346,7 +836,7 @@ func remove(node string, p *Patch, v reflect.Value) error {
 	switch v.Kind() {
 	case reflect.Array, reflect.Slice:
 		pos, err := strconv.Atoi(node)
-		if err != nil {
+		if err != nil || pos < 0 || pos >= v.Len() {
 			return ErrIncorrectIndex
 		}
 		sl := reflect.MakeSlice(v.Type(), 0, v.Len()-1)
@@ -356,12 +846,19 @@ func remove(node string, p *Patch, v reflect.Value) error {
 		return nil
 
 	case reflect.Map:
-		child := v.MapIndex(reflect.ValueOf(node))
-		v.SetMapIndex(reflect.ValueOf(node), reflect.Zero(child.Type()))
+		key := reflect.ValueOf(node)
+		if !v.MapIndex(key).IsValid() {
+			return errors.New("map element not found")
+		}
+		v.SetMapIndex(key, reflect.Value{})
 		return nil
 
 	case reflect.Struct:
-		child := v.FieldByName(strings.Title(node))
+		name := bestMatch(node, v.Type())
+		if name == "" {
+			return ErrIncorrectIndex
+		}
+		child := v.FieldByName(name)
 		child.Set(reflect.Zero(child.Type()))
 		return nil
 
@@ -370,7 +867,8 @@ func remove(node string, p *Patch, v reflect.Value) error {
 		return ErrNotImplemented
 
 	}
-	return nil
+	// primitive types cannot have children
+	return ErrNodeNil
 }
 
 func test(node string, p *Patch, v reflect.Value) error {
@@ -381,20 +879,19 @@ func test(node string, p *Patch, v reflect.Value) error {
 	switch v.Kind() {
 	case reflect.Array, reflect.Slice:
 		pos, err := strconv.Atoi(node)
-		if err != nil {
+		if err != nil || pos < 0 || pos >= v.Len() {
 			return ErrIncorrectIndex
 		}
 		child = v.Index(pos)
-		if child.Kind() == reflect.Ptr {
-			if child.IsNil() {
-				//TODO: what to do with nil
-				return nil
-			}
-			child = reflect.Indirect(child)
-		}
 
 	case reflect.Map:
-		child = v.MapIndex(reflect.ValueOf(node))
+		if v.Type().Key().Kind() != reflect.String {
+			return &ErrUnsupported{node}
+		}
+		child = v.MapIndex(reflect.ValueOf(node).Convert(v.Type().Key()))
+		if !child.IsValid() {
+			return ErrTestFailed
+		}
 
 	case reflect.Struct:
 		name := bestMatch(node, v.Type())
@@ -414,20 +911,24 @@ func test(node string, p *Patch, v reflect.Value) error {
 		// these are primitive types
 		child = v
 	}
-	m := child.Interface()
-	n := child.Interface()
-	err := json.Unmarshal(p.Value, &n)
-	if err != nil {
-		return err
+	if !child.CanInterface() {
+		return &ErrUnsupported{node}
 	}
+	return equalJSON(child, p.Value)
+}
 
-	// JSON represents numbers as float64 and thus an unmarshal would
-	// change the type of n to a float64 and needs conversion.
-	if reflect.TypeOf(m) != reflect.TypeOf(n) {
-		n = reflect.ValueOf(n).Convert(reflect.TypeOf(m)).Interface()
+// equalJSON returns ErrTestFailed unless v equals the JSON value. The value
+// is decoded into the type of v, so numbers compare equal regardless of
+// their type. A value which cannot be decoded into it, e.g. a number tested
+// against a string, is not equal.
+func equalJSON(v reflect.Value, value json.RawMessage) error {
+	n := reflect.New(v.Type())
+	err := json.Unmarshal(value, n.Interface())
+	if err != nil {
+		return ErrTestFailed
 	}
-	if !reflect.DeepEqual(n, m) {
-		return errors.New("elements are not equal")
+	if !deep.Equal(n.Elem().Interface(), v.Interface()) {
+		return ErrTestFailed
 	}
 	return nil
 }
@@ -1,6 +1,8 @@
 package jsonpatch
 
 import (
+	"context"
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -17,12 +19,35 @@ type testUser struct {
 func TestBestMatch(t *testing.T) {
 	type Test struct {
 		A string `json:"AwesomeName"`
+		B string `yaml:"replicas"`
+		C string `json:"c,omitempty" yaml:",flow"`
 	}
 
 	ty := reflect.TypeOf(Test{})
 	if name := bestMatch("AwesomeName", ty); name != "A" {
 		t.Fatal("best match did not work", name)
 	}
+	if name := bestMatch("replicas", ty); name != "B" {
+		t.Fatal("best match did not honor the yaml tag", name)
+	}
+	for _, option := range []string{"omitempty", "flow"} {
+		if name := bestMatch(option, ty); name != "" {
+			t.Fatal("best match matched the tag option", option, name)
+		}
+	}
+
+	type Shadowed struct {
+		A string `json:"B"`
+		B string
+		*Test
+	}
+	ty = reflect.TypeOf(Shadowed{})
+	if name := bestMatch("B", ty); name != "B" {
+		t.Fatal("best match preferred the tag over the field name", name)
+	}
+	if name := bestMatch("C", ty); name != "" {
+		t.Fatal("best match matched a promoted field", name)
+	}
 }
 
 func TestAdd(t *testing.T) {
@@ -208,10 +233,143 @@ func TestTest(t *testing.T) {
 		{"op": "test", "path": "/email", "value": "hobbes@calvin.com"},
 		{"op": "test", "path": "/child/name", "value": "Susie"},
 		{"op": "test", "path": "/phones/0", "value": "8390240670"},
-		{"op": "test", "path": "/m/a", "value": "hello"}
+		{"op": "test", "path": "/m/a", "value": "hello"},
+		{"op": "test", "path": "/phones", "value": ["8390240670"]},
+		{"op": "test", "path": "/child/child", "value": null}
 	]`)
 	err := Apply(p, &u)
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	failing := map[string]error{
+		`{"op": "test", "path": "/name", "value": 5}`:      ErrTestFailed,
+		`{"op": "test", "path": "/age", "value": "100"}`:   ErrTestFailed,
+		`{"op": "test", "path": "/age", "value": 100.5}`:   ErrTestFailed,
+		`{"op": "test", "path": "/m/missing", "value": 1}`: ErrTestFailed,
+		`{"op": "test", "path": "/child", "value": null}`:  ErrTestFailed,
+		`{"op": "test", "path": "/phones/1", "value": 1}`:  ErrIncorrectIndex,
+		`{"op": "test", "path": "/phones/-1", "value": 1}`: ErrIncorrectIndex,
+	}
+	for op, expected := range failing {
+		err = Apply([]byte("["+op+"]"), &u)
+		if err != expected {
+			t.Errorf("%s: expected %v, got %v", op, expected, err)
+		}
+	}
+}
+
+func TestApplyContext(t *testing.T) {
+	u := testUser{Name: "hobbes"}
+	p := []byte(`[{"op": "replace", "path": "/name", "value": "Calvin"}]`)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := ApplyContext(ctx, p, &u)
+	if err != context.Canceled {
+		t.Fatal("expected context.Canceled, got", err)
+	}
+	if u.Name != "hobbes" {
+		t.Fatal("patch applied after cancellation", u)
+	}
+
+	err = ApplyContext(context.Background(), p, &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "Calvin" {
+		t.Fatal("name not set")
+	}
+}
+
+type testEvent struct {
+	testAddress
+	Payload interface{}
+	Err     error
+}
+
+func TestApplyEmbeddedAndInterfaces(t *testing.T) {
+	e := testEvent{
+		testAddress: testAddress{City: "Berlin"},
+		Payload:     map[string]interface{}{"count": 1.0},
+		Err:         errors.New("failed"),
+	}
+	p := []byte(`[
+		{"op": "replace", "path": "/Payload/count", "value": 2},
+		{"op": "replace", "path": "/Name", "value": "x"}
+	]`)
+	err := Apply(p, &e)
+	if err == nil {
+		t.Fatal("was supposed to fail")
+	}
+	if e.Payload.(map[string]interface{})["count"] != 1.0 || e.City != "Berlin" || e.Err.Error() != "failed" {
+		t.Fatal("failed patch modified the value", e)
+	}
+	p = []byte(`[{"op": "replace", "path": "/Payload/count", "value": 2}]`)
+	err = Apply(p, &e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Payload.(map[string]interface{})["count"] != 2.0 {
+		t.Fatal("patch was not applied", e)
+	}
+}
+
+type testCounter struct {
+	Count int
+	Name  string
+}
+
+// ApplyOp increments Count on any replace of /count and leaves everything
+// else to reflection.
+func (c *testCounter) ApplyOp(p *Patch) error {
+	if p.Op == "replace" && p.Path == "/count" {
+		c.Count++
+		return nil
+	}
+	return ErrNotImplemented
+}
+
+func TestApplyPatchable(t *testing.T) {
+	var c testCounter
+	p := []byte(`[
+		{"op": "replace", "path": "/count", "value": 5},
+		{"op": "replace", "path": "/Name", "value": "x"}
+	]`)
+	err := Apply(p, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Count != 1 || c.Name != "x" {
+		t.Fatal("operations were not delegated", c)
+	}
+}
+
+type testCopied struct {
+	Name   string
+	Copies int
+	Err    error `json:"-"`
+}
+
+func (c *testCopied) DeepCopy() (*testCopied, error) {
+	if c.Err != nil {
+		return nil, c.Err
+	}
+	return &testCopied{Name: c.Name, Copies: c.Copies + 1}, nil
+}
+
+func TestApplyDeepCopy(t *testing.T) {
+	c := testCopied{Name: "a"}
+	err := Apply([]byte(`[{"op": "replace", "path": "/Name", "value": "b"}]`), &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "b" || c.Copies != 1 {
+		t.Fatal("DeepCopy was not used", c)
+	}
+
+	c.Err = errors.New("failed")
+	err = Apply([]byte(`[{"op": "replace", "path": "/Name", "value": "c"}]`), &c)
+	if err != ErrCouldNotCopy || c.Name != "b" {
+		t.Fatal("expected ErrCouldNotCopy, got", err, c)
+	}
 }
@@ -0,0 +1,58 @@
+package jsonpatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Explanation describes the effect of a single operation of a patch, or
+// the reason it fails.
+type Explanation struct {
+	Change
+	// Err is the reason the operation fails.
+	Err error
+}
+
+// String returns a human readable description of the operation.
+func (e Explanation) String() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s %s (%s): fails: %v", e.Op, e.Pointer, e.Field, e.Err)
+	}
+	if e.Op == "test" {
+		return fmt.Sprintf("%s %s (%s): equals %s", e.Op, e.Pointer, e.Field, format(e.Old))
+	}
+	return fmt.Sprintf("%s %s (%s): %s -> %s", e.Op, e.Pointer, e.Field, format(e.Old), format(e.New))
+}
+
+// format returns the JSON encoding of v for use in explanations.
+func format(v interface{}) string {
+	if v == nil {
+		return "(none)"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+// Explain applies a patch to a copy of x in the same way as ApplyWithOptions
+// with DryRun set and explains what each of the operations does. A move is
+// explained by its changes to both locations, as reported by ApplyReport. x
+// is never modified. With nil opts the patch is applied as by Apply, without
+// any of the checks of the options.
+//
+// The returned error is the reason the patch fails. If it is caused by one
+// of the operations, the last explanation describes it and the following
+// operations are not explained.
+func Explain(data []byte, x interface{}, opts *ApplyOptions) ([]Explanation, error) {
+	if opts != nil {
+		o := *opts
+		o.DryRun = true
+		opts = &o
+	}
+	t := &tracker{explain: true}
+	err := apply(context.Background(), data, x, opts, t)
+	return t.explanations, err
+}
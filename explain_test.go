@@ -0,0 +1,82 @@
+package jsonpatch
+
+import (
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	c := testCustomer{
+		Name: "Calvin",
+		Tags: []string{"a"},
+	}
+	p := []byte(`[
+		{"op": "test", "path": "/name", "value": "Calvin"},
+		{"op": "replace", "path": "/address/city", "value": "Amsterdam"},
+		{"op": "add", "path": "/tags/-", "value": "b"},
+		{"op": "move", "from": "/tags/0", "path": "/name"},
+		{"op": "replace", "path": "/tags/5", "value": "c"},
+		{"op": "remove", "path": "/name"}
+	]`)
+	explanations, err := Explain(p, &c, nil)
+	if err != ErrIncorrectIndex {
+		t.Fatal("expected the patch to fail, got", err)
+	}
+	expected := []string{
+		`test /name (testCustomer.Name): equals "Calvin"`,
+		`replace /address/city (testCustomer.Address.City): "" -> "Amsterdam"`,
+		`add /tags/1 (testCustomer.Tags[1]): (none) -> "b"`,
+		`move /tags/0 (testCustomer.Tags[0]): "a" -> (none)`,
+		`move /name (testCustomer.Name): "Calvin" -> "a"`,
+		`replace /tags/5 (testCustomer.Tags[5]): fails: jsonpatch: incorrect index`,
+	}
+	if len(explanations) != len(expected) {
+		t.Fatal("unexpected explanations", explanations)
+	}
+	for i, e := range explanations {
+		if e.String() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], e)
+		}
+	}
+	if c.Name != "Calvin" || c.Address.City != "" || len(c.Tags) != 1 {
+		t.Fatal("explained patch was applied", c)
+	}
+}
+
+func TestApplyWithOptionsDryRun(t *testing.T) {
+	u := testUser{Name: "hobbes"}
+	p := []byte(`[{"op": "replace", "path": "/name", "value": "Calvin"}]`)
+	err := ApplyWithOptions(p, &u, &ApplyOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "hobbes" {
+		t.Fatal("dry run was committed", u)
+	}
+	p = []byte(`[{"op": "replace", "path": "/nickname", "value": "Calvin"}]`)
+	err = ApplyWithOptions(p, &u, &ApplyOptions{DryRun: true})
+	if err == nil {
+		t.Fatal("was supposed to fail")
+	}
+}
+
+func TestExplainWithoutOptions(t *testing.T) {
+	a := testAccount{ID: "1"}
+	p := []byte(`[{"op": "replace", "path": "/id", "value": "2"}]`)
+	_, err := Explain(p, &a, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = Explain(p, &a, &ApplyOptions{})
+	if err == nil {
+		t.Fatal("expected the tags to be checked")
+	}
+	p = []byte(`[{"op": "replace", "path": "", "value": 1}]`)
+	_, err = Explain(p, &a, nil)
+	expected := Apply(p, &a)
+	if err == nil || err.Error() != expected.Error() {
+		t.Fatalf("expected %v, got %v", expected, err)
+	}
+	if a.ID != "1" {
+		t.Fatal("explained patch was applied", a)
+	}
+}
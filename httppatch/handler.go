@@ -0,0 +1,220 @@
+// Package httppatch implements an HTTP handler for PATCH endpoints accepting
+// JSON patches (RFC 6902) and JSON merge patches (RFC 7386).
+package httppatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/optiopay/jsonpatch"
+)
+
+// Media types of the patch formats.
+const (
+	JSONPatch  = "application/json-patch+json"
+	MergePatch = "application/merge-patch+json"
+)
+
+// defaultMaxBodyBytes is the size limit of request bodies used when
+// Handler.MaxBodyBytes is zero.
+const defaultMaxBodyBytes = 1 << 20
+
+// Problem describes an error as defined in RFC 7807. The callbacks of
+// Handler return a *Problem to have it sent to the client, any other error
+// results in a 500 Internal Server Error.
+type Problem struct {
+	Type   string `json:"type,omitempty"`
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func (p *Problem) Error() string {
+	return fmt.Sprintf("httppatch: %d %s: %s", p.Status, p.Title, p.Detail)
+}
+
+// Handler handles PATCH requests of a resource.
+//
+// The version of the resource used with If-Match and ETag is its
+// jsonpatch.Version. Patches are applied with the context of the
+// request, so that they are abandoned when the client goes away.
+type Handler struct {
+	// Load returns a pointer to the resource the request refers to.
+	Load func(r *http.Request) (interface{}, error)
+	// Save stores the patched resource.
+	Save func(r *http.Request, x interface{}) error
+	// Options configures how patches are applied. It may be nil.
+	Options *jsonpatch.ApplyOptions
+	// MaxBodyBytes limits the size of the patch. It defaults to 1 MiB.
+	MaxBodyBytes int64
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		w.Header().Set("Allow", http.MethodPatch)
+		writeProblem(w, &Problem{Title: "Method Not Allowed", Status: http.StatusMethodNotAllowed})
+		return
+	}
+	media, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (media != JSONPatch && media != MergePatch) {
+		w.Header().Set("Accept-Patch", JSONPatch+", "+MergePatch)
+		writeProblem(w, &Problem{
+			Title:  "Unsupported Media Type",
+			Status: http.StatusUnsupportedMediaType,
+			Detail: fmt.Sprintf("expected %s or %s", JSONPatch, MergePatch),
+		})
+		return
+	}
+
+	limit := h.MaxBodyBytes
+	if limit == 0 {
+		limit = defaultMaxBodyBytes
+	}
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		writeProblem(w, &Problem{
+			Title:  "Payload Too Large",
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: fmt.Sprintf("the patch exceeds %d bytes", tooLarge.Limit),
+		})
+		return
+	}
+	if err != nil {
+		writeProblem(w, badRequest(err))
+		return
+	}
+	err = validate(media, data)
+	if err != nil {
+		writeProblem(w, badRequest(err))
+		return
+	}
+
+	x, err := h.Load(r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	match := r.Header.Get("If-Match")
+	if match != "" {
+		etag, err := jsonpatch.Version(x)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !matches(match, etag) {
+			writeProblem(w, &Problem{
+				Title:  "Precondition Failed",
+				Status: http.StatusPreconditionFailed,
+				Detail: "the resource has been modified",
+			})
+			return
+		}
+	}
+
+	if media == JSONPatch {
+		err = jsonpatch.ApplyWithOptionsContext(r.Context(), data, x, h.Options)
+	} else {
+		err = jsonpatch.ApplyMergePatchContext(r.Context(), data, x, h.Options)
+	}
+	if err != nil {
+		writeProblem(w, problemOf(err))
+		return
+	}
+	if h.Save != nil {
+		err = h.Save(r, x)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+
+	body, err := json.Marshal(x)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	etag, err := jsonpatch.Version(x)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	w.Header().Set("ETag", `"`+etag+`"`)
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
+
+// validate checks that data is a well-formed patch of the media type.
+func validate(media string, data []byte) error {
+	if media == JSONPatch {
+		var patches []jsonpatch.Patch
+		return json.Unmarshal(data, &patches)
+	}
+	var patch map[string]json.RawMessage
+	err := json.Unmarshal(data, &patch)
+	if err != nil {
+		return err
+	}
+	if patch == nil {
+		return jsonpatch.ErrUnmarshal
+	}
+	return nil
+}
+
+// problemOf maps an error returned while applying a patch to a Problem.
+func problemOf(err error) *Problem {
+	switch e := err.(type) {
+	case *jsonpatch.ErrUnknownOp:
+		return badRequest(e)
+	case *jsonpatch.ErrPrecondition:
+		return &Problem{Title: "Precondition Failed", Status: http.StatusPreconditionFailed, Detail: e.Error()}
+	case *Problem:
+		return e
+	}
+	if err == context.Canceled || err == context.DeadlineExceeded {
+		return &Problem{Title: "Service Unavailable", Status: http.StatusServiceUnavailable, Detail: err.Error()}
+	}
+	if err == jsonpatch.ErrTestFailed {
+		return &Problem{Title: "Conflict", Status: http.StatusConflict, Detail: err.Error()}
+	}
+	// permissions, limits, validation and paths which do not exist
+	return &Problem{Title: "Unprocessable Entity", Status: http.StatusUnprocessableEntity, Detail: err.Error()}
+}
+
+func badRequest(err error) *Problem {
+	return &Problem{Title: "Bad Request", Status: http.StatusBadRequest, Detail: err.Error()}
+}
+
+// writeError writes err if it is a *Problem, or an Internal Server Error.
+func writeError(w http.ResponseWriter, err error) {
+	p, ok := err.(*Problem)
+	if !ok {
+		p = &Problem{Title: "Internal Server Error", Status: http.StatusInternalServerError}
+	}
+	writeProblem(w, p)
+}
+
+func writeProblem(w http.ResponseWriter, p *Problem) {
+	body, _ := json.Marshal(p)
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	w.Write(body)
+}
+
+// matches reports whether the If-Match header value matches etag. Weak
+// entity tags never match.
+func matches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == `"`+etag+`"` {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+package httppatch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/optiopay/jsonpatch"
+)
+
+type testUser struct {
+	Name  string            `json:"name"`
+	Email string            `json:"email"`
+	Role  string            `json:"role"`
+	Tags  []string          `json:"tags"`
+	Meta  map[string]string `json:"meta"`
+}
+
+type testStore struct {
+	users map[string]testUser
+}
+
+func (s *testStore) handler() *Handler {
+	return &Handler{
+		Load: func(r *http.Request) (interface{}, error) {
+			u, ok := s.users[r.URL.Path]
+			if !ok {
+				return nil, &Problem{Title: "Not Found", Status: http.StatusNotFound}
+			}
+			return &u, nil
+		},
+		Save: func(r *http.Request, x interface{}) error {
+			if x.(*testUser).Name == "" {
+				return errors.New("cannot save")
+			}
+			s.users[r.URL.Path] = *x.(*testUser)
+			return nil
+		},
+		Options: &jsonpatch.ApplyOptions{Deny: []string{"/role"}},
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := &testStore{users: map[string]testUser{"/hobbes": {Name: "hobbes", Role: "tiger"}}}
+	h := s.handler()
+	etag, _ := jsonpatch.Hash(&testUser{Name: "hobbes", Role: "tiger"})
+
+	tests := []struct {
+		method, path, media, ifMatch string
+		body                         string
+		status                       int
+	}{
+		{"GET", "/hobbes", JSONPatch, "", `[]`, http.StatusMethodNotAllowed},
+		{"PATCH", "/hobbes", "application/json", "", `[]`, http.StatusUnsupportedMediaType},
+		{"PATCH", "/hobbes", JSONPatch, "", `{`, http.StatusBadRequest},
+		{"PATCH", "/hobbes", MergePatch, "", `[]`, http.StatusBadRequest},
+		{"PATCH", "/calvin", JSONPatch, "", `[]`, http.StatusNotFound},
+		{"PATCH", "/hobbes", JSONPatch, `"stale"`, `[]`, http.StatusPreconditionFailed},
+		{"PATCH", "/hobbes", JSONPatch, "", `[{"op": "test", "path": "/name", "value": "Calvin"}]`, http.StatusConflict},
+		{"PATCH", "/hobbes", JSONPatch, "", `[{"op": "test", "path": "/name", "value": 5}]`, http.StatusConflict},
+		{"PATCH", "/hobbes", JSONPatch, "", `[{"op": "test", "path": "/name", "value": {"a": [1]}}]`, http.StatusConflict},
+		{"PATCH", "/hobbes", JSONPatch, "", `[{"op": "test", "path": "/meta/missing", "value": "x"}]`, http.StatusConflict},
+		{"PATCH", "/hobbes", JSONPatch, "", `[{"op": "test", "path": "/tags/3", "value": "x"}]`, http.StatusUnprocessableEntity},
+		{"PATCH", "/hobbes", JSONPatch, "", `[{"op": "replace", "path": "/role", "value": "boy"}]`, http.StatusUnprocessableEntity},
+		{"PATCH", "/hobbes", JSONPatch, "", `[{"op": "frobnicate", "path": "/name"}]`, http.StatusBadRequest},
+		{"PATCH", "/hobbes", MergePatch, "", `{"name": null}`, http.StatusInternalServerError},
+		{"PATCH", "/hobbes", JSONPatch, `"` + etag + `"`, `[{"op": "replace", "path": "/email", "value": "hobbes@example.com"}]`, http.StatusOK},
+		{"PATCH", "/hobbes", MergePatch + "; charset=utf-8", "*", `{"name": "Hobbes"}`, http.StatusOK},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(test.method, test.path, strings.NewReader(test.body))
+		r.Header.Set("Content-Type", test.media)
+		if test.ifMatch != "" {
+			r.Header.Set("If-Match", test.ifMatch)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%s %s %s: expected %d, got %d %s", test.method, test.path, test.body, test.status, w.Code, w.Body)
+			continue
+		}
+		if test.status != http.StatusOK {
+			var p Problem
+			err := json.Unmarshal(w.Body.Bytes(), &p)
+			if err != nil || p.Status != test.status || w.Header().Get("Content-Type") != "application/problem+json" {
+				t.Errorf("%s: unexpected problem %s", test.body, w.Body)
+			}
+		}
+	}
+
+	expected := testUser{Name: "Hobbes", Email: "hobbes@example.com", Role: "tiger"}
+	if !reflect.DeepEqual(s.users["/hobbes"], expected) {
+		t.Fatal("unexpected resource", s.users["/hobbes"])
+	}
+}
+
+func TestHandlerETag(t *testing.T) {
+	s := &testStore{users: map[string]testUser{"/hobbes": {Name: "hobbes"}}}
+	h := s.handler()
+	r := httptest.NewRequest("PATCH", "/hobbes", strings.NewReader(`{"email": "hobbes@example.com"}`))
+	r.Header.Set("Content-Type", MergePatch)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	u := s.users["/hobbes"]
+	etag, _ := jsonpatch.Hash(&u)
+	if w.Header().Get("ETag") != `"`+etag+`"` {
+		t.Fatal("unexpected ETag", w.Header().Get("ETag"))
+	}
+	var body testUser
+	json.Unmarshal(w.Body.Bytes(), &body)
+	if !reflect.DeepEqual(body, u) {
+		t.Fatal("unexpected body", w.Body)
+	}
+}
+
+func TestHandlerCanceled(t *testing.T) {
+	s := &testStore{users: map[string]testUser{"/hobbes": {Name: "hobbes"}}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r := httptest.NewRequest("PATCH", "/hobbes", strings.NewReader(`{"name": "Calvin"}`)).WithContext(ctx)
+	r.Header.Set("Content-Type", MergePatch)
+	w := httptest.NewRecorder()
+	s.handler().ServeHTTP(w, r)
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected %d, got %d %s", http.StatusServiceUnavailable, w.Code, w.Body)
+	}
+	if s.users["/hobbes"].Name != "hobbes" {
+		t.Fatal("patch applied after cancellation", s.users["/hobbes"])
+	}
+}
+
+func TestHandlerTooLarge(t *testing.T) {
+	s := &testStore{users: map[string]testUser{"/hobbes": {Name: "hobbes"}}}
+	h := s.handler()
+	h.MaxBodyBytes = 8
+	r := httptest.NewRequest("PATCH", "/hobbes", strings.NewReader(`{"name": "Calvin"}`))
+	r.Header.Set("Content-Type", MergePatch)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected %d, got %d %s", http.StatusRequestEntityTooLarge, w.Code, w.Body)
+	}
+	if s.users["/hobbes"].Name != "hobbes" {
+		t.Fatal("oversized patch applied", s.users["/hobbes"])
+	}
+}
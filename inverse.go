@@ -0,0 +1,146 @@
+package jsonpatch
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// ApplyWithInverse applies a patch to x in the same way as Apply and returns
+// a patch which restores the original value when applied to the result.
+//
+// A replace records the previous value, a remove is undone by adding the
+// removed value back, and an add is undone by removing the added value or,
+// when the location existed before, by replacing it with its old value.
+// A copy is undone in the same way as an add, and a move by moving the value
+// back and restoring the location it was moved to. Test operations do not
+// change anything and have no inverse. Custom operations are undone by
+// restoring the previous value of their location.
+func ApplyWithInverse(data []byte, x interface{}) ([]byte, error) {
+	t := &tracker{invert: true, inverse: []Patch{}}
+	err := apply(context.Background(), data, x, nil, t)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(t.inverse)
+}
+
+// invert returns the operations which undo p. It has to be called before p
+// is applied to x.
+func invert(path string, p *Patch, x reflect.Value) ([]Patch, error) {
+	if p.Op == "test" {
+		return nil, nil
+	}
+	if p.Path == "" {
+		// the whole value is restored
+		return restore("", x)
+	}
+	if p.Op == "move" {
+		return invertMove(path, p, x)
+	}
+	if prefix, old, ok := nilBranch(path, x); ok {
+		return restore("/"+prefix, old)
+	}
+
+	parent := indirect(parentOf(path, x))
+	old, ok := lookup(path, x)
+
+	switch p.Op {
+	case "add", "copy":
+		if parent.Kind() == reflect.Slice {
+			return []Patch{{Op: "remove", Path: "/" + resolveEnd(path, x)}}, nil
+		}
+		if !ok {
+			return []Patch{{Op: "remove", Path: "/" + path}}, nil
+		}
+		return restore("/"+path, old)
+
+	case "replace":
+		if !ok {
+			// the operation is going to fail anyway
+			return nil, nil
+		}
+		return restore("/"+path, old)
+
+	case "remove":
+		if !ok {
+			return nil, nil
+		}
+		if parent.Kind() == reflect.Slice || parent.Kind() == reflect.Map {
+			value, err := json.Marshal(old.Interface())
+			if err != nil {
+				return nil, err
+			}
+			return []Patch{{Op: "add", Path: "/" + path, Value: value}}, nil
+		}
+		return restore("/"+path, old)
+	}
+
+	// Custom operations are undone by restoring the location.
+	if !ok {
+		return []Patch{{Op: "remove", Path: "/" + path}}, nil
+	}
+	return restore("/"+path, old)
+}
+
+// invertMove returns the operations which undo the move p: moving the value
+// back, and restoring the location it was moved to if it existed before.
+func invertMove(path string, p *Patch, x reflect.Value) ([]Patch, error) {
+	from := strings.TrimPrefix(p.From, "/")
+	if from == path {
+		return nil, nil
+	}
+	parent := indirect(parentOf(path, x))
+	old, ok := lookup(path, x)
+	to := resolveMoveEnd(path, from, x)
+	undo := []Patch{{Op: "move", From: "/" + to, Path: "/" + from}}
+
+	if prefix, old, ok := nilBranch(path, x); ok {
+		branch, err := restore("/"+prefix, old)
+		if err != nil {
+			return nil, err
+		}
+		return append(undo, branch...), nil
+	}
+	if ok && parent.Kind() != reflect.Slice {
+		// the value at path was overwritten
+		value, err := json.Marshal(old.Interface())
+		if err != nil {
+			return nil, err
+		}
+		undo = append(undo, Patch{Op: "add", Path: "/" + path, Value: value})
+	}
+	return undo, nil
+}
+
+// nilBranch returns the first parent of path which is nil, if any, together
+// with its value. Patching below a nil pointer or map allocates it, which
+// removing the leaf alone would not undo, so the whole branch is restored.
+func nilBranch(path string, x reflect.Value) (string, reflect.Value, bool) {
+	nodes := strings.Split(path, "/")
+	for i := 1; i < len(nodes); i++ {
+		prefix := strings.Join(nodes[:i], "/")
+		old, ok := lookup(prefix, x)
+		if !ok {
+			// applying the operation fails later on
+			return "", reflect.Value{}, false
+		}
+		switch old.Kind() {
+		case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+			if old.IsNil() {
+				return prefix, old, true
+			}
+		}
+	}
+	return "", reflect.Value{}, false
+}
+
+// restore returns a replace operation setting path back to old.
+func restore(path string, old reflect.Value) ([]Patch, error) {
+	value, err := json.Marshal(old.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return []Patch{{Op: "replace", Path: path, Value: value}}, nil
+}
@@ -0,0 +1,137 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/optiopay/jsonpatch/deep"
+)
+
+func TestApplyWithInverse(t *testing.T) {
+	u := testUser{
+		Name:   "hobbes",
+		Age:    100,
+		Phones: []string{"12830921", "8390240670"},
+		M:      map[string]string{"a": "hello"},
+	}
+	var orig testUser
+	if err := deep.Copy(&u, &orig); err != nil {
+		t.Fatal(err)
+	}
+
+	p := []byte(`[
+		{"op": "replace", "path": "/name", "value": "Calvin"},
+		{"op": "add", "path": "/age", "value": 6},
+		{"op": "add", "path": "/child/name", "value": "Susie"},
+		{"op": "add", "path": "/child/age", "value": 6},
+		{"op": "add", "path": "/phones/-", "value": "9096040676"},
+		{"op": "remove", "path": "/phones/0"},
+		{"op": "add", "path": "/m/b", "value": "world"},
+		{"op": "remove", "path": "/m/a"},
+		{"op": "test", "path": "/m/b", "value": "world"}
+	]`)
+	inverse, err := ApplyWithInverse(p, &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "Calvin" || u.Child == nil || len(u.Phones) != 2 {
+		t.Fatal("patch not applied", u)
+	}
+
+	err = Apply(inverse, &u)
+	if err != nil {
+		t.Fatal(err, string(inverse))
+	}
+	if !reflect.DeepEqual(u, orig) {
+		t.Fatalf("inverse %s did not restore the original: %+v", inverse, u)
+	}
+}
+
+func TestApplyWithInverseSlice(t *testing.T) {
+	a := []string{"a", "b", "c"}
+	p := []byte(`[
+		{"op": "add", "path": "/1", "value": "x"},
+		{"op": "remove", "path": "/3"},
+		{"op": "replace", "path": "/0", "value": "y"}
+	]`)
+	inverse, err := ApplyWithInverse(p, &a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, []string{"y", "x", "b"}) {
+		t.Fatal("patch not applied", a)
+	}
+	err = Apply(inverse, &a)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(a, []string{"a", "b", "c"}) {
+		t.Fatalf("inverse %s did not restore the original: %v", inverse, a)
+	}
+}
+
+func TestApplyWithInverseMoveAndCopy(t *testing.T) {
+	tests := []string{
+		`[{"op": "move", "from": "/0", "path": "/2"}]`,
+		`[{"op": "move", "from": "/2", "path": "/0"}]`,
+		`[{"op": "move", "from": "/0", "path": "/-"}]`,
+		`[{"op": "move", "from": "/1/a", "path": "/1/b"}]`,
+		`[{"op": "move", "from": "/1/a", "path": "/1/c"}]`,
+		`[{"op": "move", "from": "/2", "path": "/1/d"}]`,
+		`[{"op": "move", "from": "/0", "path": "/0"}]`,
+		`[{"op": "copy", "from": "/0", "path": "/-"}]`,
+		`[{"op": "copy", "from": "/1/a", "path": "/1/b"}]`,
+		`[{"op": "copy", "from": "/1/a", "path": "/1/c"}]`,
+		`[{"op": "copy", "from": "/1", "path": "/2/d"},
+		  {"op": "move", "from": "/2/d/a", "path": "/0"}]`,
+	}
+	for _, p := range tests {
+		var doc, orig interface{}
+		for _, v := range []*interface{}{&doc, &orig} {
+			err := json.Unmarshal([]byte(`["x", {"a": 1, "b": 2}, {}]`), v)
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		inverse, err := ApplyWithInverse([]byte(p), &doc)
+		if err != nil {
+			t.Fatal(p, err)
+		}
+		err = Apply(inverse, &doc)
+		if err != nil {
+			t.Fatal(p, string(inverse), err)
+		}
+		if !reflect.DeepEqual(doc, orig) {
+			t.Errorf("%s: inverse %s did not restore the original: %v", p, inverse, doc)
+		}
+	}
+
+	u := testUser{Name: "Calvin", Phones: []string{"1"}}
+	inverse, err := ApplyWithInverse([]byte(`[{"op": "move", "from": "/name", "path": "/child/name"}]`), &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = Apply(inverse, &u)
+	if err != nil {
+		t.Fatal(string(inverse), err)
+	}
+	if !reflect.DeepEqual(u, testUser{Name: "Calvin", Phones: []string{"1"}}) {
+		t.Fatalf("inverse %s did not restore the original: %+v", inverse, u)
+	}
+}
+
+func TestApplyWithInverseFailure(t *testing.T) {
+	u := testUser{Name: "hobbes"}
+	p := []byte(`[
+		{"op": "replace", "path": "/name", "value": "Calvin"},
+		{"op": "test", "path": "/name", "value": "hobbes"}
+	]`)
+	inverse, err := ApplyWithInverse(p, &u)
+	if err == nil {
+		t.Fatal("was supposed to fail")
+	}
+	if inverse != nil || u.Name != "hobbes" {
+		t.Fatal("failed patch was applied", u, inverse)
+	}
+}
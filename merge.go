@@ -0,0 +1,143 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/optiopay/jsonpatch/deep"
+)
+
+// Conflict describes an operation of the second patch passed to Merge which
+// interferes with an operation of the first one.
+type Conflict struct {
+	// Patch is the operation of the second patch.
+	Patch Patch
+	// With is the operation it conflicts with. It is the operation of
+	// the first patch, or one of the second patch Patch depends on.
+	With Patch
+	// Reason describes the conflict.
+	Reason string
+}
+
+func (c Conflict) String() string {
+	return fmt.Sprintf("%s %s conflicts with %s %s: %s", c.Patch.Op, c.Patch.Path, c.With.Op, c.With.Path, c.Reason)
+}
+
+// Merge combines two patches which were both written against base. It
+// rebases p2 over p1 and returns a patch which applies p1 followed by the
+// rebased p2 to base.
+//
+// Operations of p2 which conflict with p1 are left out of the merged patch
+// and returned for the caller to resolve. This is the case when both set
+// the same location to different values, when p1 removes or replaces a
+// parent of a location p2 modifies or the other way round, when p1 removes
+// an array element p2 refers to, and when p2 tests a location p1 modifies.
+// A move conflicts with every operation of the other patch reading or
+// modifying the moved value, its source or its target, and a copy with
+// every one modifying the copied value or reading or modifying its target.
+// Operations of p2 which depend on a conflicting one are left out too. The
+// array indices of the remaining operations of p2 are adjusted to the
+// elements p1 inserted and removed.
+//
+// Both patches have to apply to base, which is never modified.
+func Merge(base interface{}, p1, p2 []byte) ([]byte, []Conflict, error) {
+	v := reflect.ValueOf(base)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil, ErrNonPointer
+		}
+		v = v.Elem()
+	}
+	as, err := resolvePatch(p1, v)
+	if err != nil {
+		return nil, nil, err
+	}
+	bs, err := resolvePatch(p2, v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	t := &transformer{element: elementOf(v)}
+	_, rebased, conflicts, err := t.transform(as, bs, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	merged, err := json.Marshal(append(as, rebased...))
+	if err != nil {
+		return nil, nil, err
+	}
+	return merged, conflicts, nil
+}
+
+// resolvePatch applies the patch to a copy of v and returns its operations
+// with every trailing "-" replaced by the index the element is appended at.
+func resolvePatch(data []byte, v reflect.Value) ([]Patch, error) {
+	var patches []Patch
+	err := json.Unmarshal(data, &patches)
+	if err != nil {
+		return nil, err
+	}
+	x := reflect.New(v.Type())
+	x.Elem().Set(v)
+	y := reflect.New(v.Type())
+	err = deep.Copy(x.Interface(), y.Interface())
+	if err != nil {
+		return nil, ErrCouldNotCopy
+	}
+	for i := range patches {
+		path, _, err := parsePointer(patches[i].Path)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case patches[i].Path == "":
+		case patches[i].Op == "move":
+			patches[i].Path = "/" + resolveMoveEnd(path, strings.TrimPrefix(patches[i].From, "/"), y)
+		default:
+			patches[i].Path = "/" + resolveEnd(path, y)
+		}
+		err = applyOp(path, &patches[i], y)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return patches, nil
+}
+
+// elementOf returns a function reporting whether a path points to an
+// element of an array within v.
+func elementOf(v reflect.Value) func([]string) bool {
+	return func(path []string) bool {
+		if len(path) == 0 {
+			return false
+		}
+		container := path[:len(path)-1]
+		t := v.Type()
+		for _, node := range container {
+			for t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			switch t.Kind() {
+			case reflect.Struct:
+				f, ok := t.FieldByName(bestMatch(node, t))
+				if !ok {
+					return false
+				}
+				t = f.Type
+			case reflect.Slice, reflect.Array, reflect.Map:
+				t = t.Elem()
+			default:
+				// the type is not known until run time
+				c, _ := lookup(strings.Join(container, "/"), v)
+				c = indirect(c)
+				return c.Kind() == reflect.Slice || c.Kind() == reflect.Array
+			}
+		}
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		return t.Kind() == reflect.Slice || t.Kind() == reflect.Array
+	}
+}
@@ -0,0 +1,122 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	base := testDoc{
+		Name:  "base",
+		Tags:  []string{"a", "b"},
+		Items: []testItem{{Name: "x", Price: 1}, {Name: "y", Price: 2}, {Name: "z", Price: 3}},
+		M:     map[string]string{"a": "b"},
+	}
+	tests := []struct {
+		p1, p2    string
+		expected  string
+		conflicts int
+	}{
+		{
+			`[{"op": "add", "path": "/tags/1", "value": "c"}]`,
+			`[{"op": "add", "path": "/tags/1", "value": "d"}]`,
+			`[{"op": "add", "path": "/tags/1", "value": "c"}, {"op": "add", "path": "/tags/2", "value": "d"}]`,
+			0,
+		},
+		{
+			`[{"op": "replace", "path": "/name", "value": "one"}]`,
+			`[{"op": "replace", "path": "/name", "value": "two"}]`,
+			`[{"op": "replace", "path": "/name", "value": "one"}]`,
+			1,
+		},
+		{
+			`[{"op": "replace", "path": "/name", "value": "one"}]`,
+			`[{"op": "replace", "path": "/name", "value": "one"}]`,
+			`[{"op": "replace", "path": "/name", "value": "one"}]`,
+			0,
+		},
+		{
+			`[{"op": "remove", "path": "/items/1"}]`,
+			`[{"op": "replace", "path": "/items/1/price", "value": 5}]`,
+			`[{"op": "remove", "path": "/items/1"}]`,
+			1,
+		},
+		{
+			`[{"op": "remove", "path": "/items/0"}]`,
+			`[{"op": "replace", "path": "/items/2/price", "value": 5}]`,
+			`[{"op": "remove", "path": "/items/0"}, {"op": "replace", "path": "/items/1/price", "value": 5}]`,
+			0,
+		},
+		{
+			`[{"op": "add", "path": "/tags/-", "value": "c"}]`,
+			`[{"op": "add", "path": "/tags/-", "value": "d"}]`,
+			`[{"op": "add", "path": "/tags/2", "value": "c"}, {"op": "add", "path": "/tags/3", "value": "d"}]`,
+			0,
+		},
+		{
+			`[{"op": "remove", "path": "/tags/0"}]`,
+			`[{"op": "add", "path": "/tags/0", "value": "c"}, {"op": "replace", "path": "/tags/0", "value": "d"}]`,
+			`[{"op": "remove", "path": "/tags/0"}, {"op": "add", "path": "/tags/0", "value": "c"}, {"op": "replace", "path": "/tags/0", "value": "d"}]`,
+			0,
+		},
+		{
+			`[{"op": "copy", "from": "/name", "path": "/m/q"}]`,
+			`[{"op": "add", "path": "/m/q", "value": "c"}]`,
+			`[{"op": "copy", "from": "/name", "path": "/m/q"}]`,
+			1,
+		},
+		{
+			`[{"op": "replace", "path": "/name", "value": "one"}]`,
+			`[{"op": "copy", "from": "/name", "path": "/m/q"}]`,
+			`[{"op": "replace", "path": "/name", "value": "one"}]`,
+			1,
+		},
+		{
+			`[{"op": "add", "path": "/tags/0", "value": "c"}]`,
+			`[{"op": "copy", "from": "/tags/0", "path": "/tags/-"}]`,
+			`[{"op": "add", "path": "/tags/0", "value": "c"}, {"op": "copy", "from": "/tags/1", "path": "/tags/3"}]`,
+			0,
+		},
+	}
+	for _, test := range tests {
+		data, conflicts, err := Merge(&base, []byte(test.p1), []byte(test.p2))
+		if err != nil {
+			t.Fatal(test.p1, test.p2, err)
+		}
+		var merged, expected []Patch
+		json.Unmarshal(data, &merged)
+		json.Unmarshal([]byte(test.expected), &expected)
+		if !reflect.DeepEqual(merged, expected) {
+			t.Errorf("merging %s and %s\ngot      %s\nexpected %s", test.p1, test.p2, data, test.expected)
+		}
+		if len(conflicts) != test.conflicts {
+			t.Errorf("merging %s and %s: expected %d conflicts, got %v", test.p1, test.p2, test.conflicts, conflicts)
+		}
+	}
+	if len(base.Items) != 3 || base.Name != "base" {
+		t.Fatal("base was modified", base)
+	}
+}
+
+func TestMergeApplies(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		var base testDoc
+		randomPatch(t, r, &base, 6)
+
+		d1, d2 := base, base
+		p1 := randomPatch(t, r, &d1, 4)
+		p2 := randomPatch(t, r, &d2, 4)
+		data, _, err := Merge(&base, p1, p2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		merged := base
+		err = Apply(data, &merged)
+		if err != nil {
+			t.Fatalf("%s\nmerged from %s\nand %s\nfailed: %v", data, p1, p2, err)
+		}
+	}
+}
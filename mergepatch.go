@@ -0,0 +1,118 @@
+package jsonpatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"sort"
+)
+
+// ApplyMergePatch applies a JSON merge patch as defined in RFC 7386 to the
+// passed interface. The merge patch has to be an object.
+//
+// The merge patch is translated into the equivalent JSON patch, which is
+// applied with the options in the same way as ApplyWithOptions does. Thus
+// the same access rules and limits apply. opts may be nil.
+func ApplyMergePatch(data []byte, x interface{}, opts *ApplyOptions) error {
+	return ApplyMergePatchContext(context.Background(), data, x, opts)
+}
+
+// ApplyMergePatchContext applies a JSON merge patch in the same way as
+// ApplyMergePatch and stops once ctx is done, as ApplyContext does.
+func ApplyMergePatchContext(ctx context.Context, data []byte, x interface{}, opts *ApplyOptions) error {
+	rx := reflect.ValueOf(x)
+	if rx.Kind() != reflect.Ptr || rx.IsNil() {
+		return ErrNonPointer
+	}
+	var patch map[string]json.RawMessage
+	err := json.Unmarshal(data, &patch)
+	if err != nil {
+		return err
+	}
+	if patch == nil {
+		return ErrUnmarshal
+	}
+	patches, err := mergeInto(nil, "", patch, rx)
+	if err != nil {
+		return err
+	}
+	data, err = json.Marshal(patches)
+	if err != nil {
+		return err
+	}
+	return apply(ctx, data, x, opts, nil)
+}
+
+// mergeInto appends the operations merging patch into the location prefix
+// of x to ops.
+func mergeInto(ops []Patch, prefix string, patch map[string]json.RawMessage, x reflect.Value) ([]Patch, error) {
+	keys := make([]string, 0, len(patch))
+	for k := range patch {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		path := prefix + "/" + escape(k)
+		value := patch[k]
+		child, ok := lookup(path[1:], x)
+		if string(value) == "null" {
+			if ok && child.IsValid() {
+				ops = append(ops, Patch{Op: "remove", Path: path})
+			}
+			continue
+		}
+		var obj map[string]json.RawMessage
+		if ok && value[0] == '{' && json.Unmarshal(value, &obj) == nil {
+			c := indirect(child)
+			if c.Kind() == reflect.Struct || c.Kind() == reflect.Map {
+				var err error
+				ops, err = mergeInto(ops, path, obj, x)
+				if err != nil {
+					return nil, err
+				}
+				continue
+			}
+		}
+		value, err := withoutNulls(value)
+		if err != nil {
+			return nil, err
+		}
+		ops = append(ops, Patch{Op: "add", Path: path, Value: value})
+	}
+	return ops, nil
+}
+
+// withoutNulls removes the members which are null from the objects within
+// value, as a merge patch adding them would.
+func withoutNulls(value json.RawMessage) (json.RawMessage, error) {
+	if !bytes.Contains(value, []byte("null")) {
+		return value, nil
+	}
+	var v interface{}
+	d := json.NewDecoder(bytes.NewReader(value))
+	d.UseNumber()
+	err := d.Decode(&v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(stripNulls(v))
+}
+
+func stripNulls(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, e := range v {
+			if e == nil {
+				delete(v, k)
+				continue
+			}
+			v[k] = stripNulls(e)
+		}
+	case []interface{}:
+		for i, e := range v {
+			v[i] = stripNulls(e)
+		}
+	}
+	return v
+}
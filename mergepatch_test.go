@@ -0,0 +1,47 @@
+package jsonpatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestApplyMergePatch(t *testing.T) {
+	u := testUser{
+		Name:   "hobbes",
+		Age:    6,
+		Phones: []string{"1", "2"},
+		M:      map[string]string{"a": "1", "b": "2"},
+	}
+	p := []byte(`{
+		"name": "Calvin",
+		"age": null,
+		"phones": ["3"],
+		"m": {"a": null, "c": "3"},
+		"child": {"name": "Susie", "email": null}
+	}`)
+	err := ApplyMergePatch(p, &u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := testUser{
+		Name:   "Calvin",
+		Phones: []string{"3"},
+		M:      map[string]string{"b": "2", "c": "3"},
+		Child:  &testUser{Name: "Susie"},
+	}
+	if !reflect.DeepEqual(u, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, u)
+	}
+
+	err = ApplyMergePatch([]byte(`{"name": "hobbes"}`), &u, &ApplyOptions{Deny: []string{"/name"}})
+	if _, ok := err.(*ErrPermission); !ok {
+		t.Fatal("expected ErrPermission, got", err)
+	}
+	err = ApplyMergePatch([]byte(`["name"]`), &u, nil)
+	if err == nil {
+		t.Fatal("merge patch which is not an object was applied")
+	}
+	if u.Name != "Calvin" {
+		t.Fatal("failed merge patch was applied", u)
+	}
+}
@@ -0,0 +1,380 @@
+package jsonpatch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ApplyOptions configures how ApplyWithOptions applies a patch.
+//
+// Besides the options, struct fields tagged `jsonpatch:"readonly"` cannot be
+// modified and fields tagged `jsonpatch:"-"` cannot be accessed at all, not
+// even by a test operation. The tags apply to everything below the field,
+// and locations above tagged fields cannot be written as a whole.
+type ApplyOptions struct {
+	// Allow lists the JSON Pointers of the locations operations may
+	// modify, together with everything below them. A "*" segment matches
+	// any single segment, e.g. /items/*/price. When Allow is empty every
+	// location not denied may be modified.
+	Allow []string
+	// Deny lists the JSON Pointers of the locations operations must not
+	// modify in the same format as Allow. Deny takes precedence over Allow.
+	// Writing a parent of a denied location is denied as well.
+	Deny []string
+
+	// The following limits protect against patches from untrusted
+	// sources. A limit of zero means no limit.
+
+	// MaxOperations limits the number of operations of a patch.
+	MaxOperations int
+	// MaxPathDepth limits the number of segments of paths.
+	MaxPathDepth int
+	// MaxValueBytes limits the size of the JSON encoded values.
+	MaxValueBytes int
+	// MaxLength limits the length of the slices and maps modified by the
+	// patch.
+	MaxLength int
+	// MaxDocumentBytes limits the size of the JSON encoding of the patched
+	// value, after each of the operations.
+	MaxDocumentBytes int
+
+	// BeforeOp is called before each of the operations is applied.
+	// Returning an error aborts the patch.
+	BeforeOp func(p Patch) error
+	// AfterOp is called with the change made by each of the operations,
+	// twice for a move as described for ApplyReport. Returning an error
+	// aborts the patch.
+	AfterOp func(c Change) error
+	// Validate is called with a pointer to the patched copy before it
+	// replaces the original. Returning an error aborts the patch.
+	Validate func(x interface{}) error
+
+	// DryRun applies the patch to the copy and runs all of the checks
+	// without replacing the original.
+	DryRun bool
+
+	// FieldResolver resolves the segments of paths which refer to struct
+	// fields, including the ones of Allow and Deny. If it is nil, paths
+	// are resolved leniently as described for the built-in resolvers.
+	FieldResolver FieldResolver
+}
+
+// ErrPermission is returned when an operation of a patch is not allowed to
+// access its location.
+type ErrPermission struct {
+	Op   string
+	Path string
+}
+
+func (e *ErrPermission) Error() string {
+	return fmt.Sprintf("jsonpatch: %s of %s is not permitted", e.Op, e.Path)
+}
+
+// ErrLimit is returned when a patch exceeds one of the limits set in
+// ApplyOptions.
+type ErrLimit struct {
+	// Limit names the exceeded limit.
+	Limit string
+	Max   int
+	// Path is the path of the offending operation, if any.
+	Path string
+}
+
+func (e *ErrLimit) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("jsonpatch: %s exceeds the limit of %d", e.Limit, e.Max)
+	}
+	return fmt.Sprintf("jsonpatch: %s of %s exceeds the limit of %d", e.Limit, e.Path, e.Max)
+}
+
+// ApplyWithOptions applies a patch to x in the same way as Apply. All of the
+// operations are checked against opts before any of them is applied.
+func ApplyWithOptions(data []byte, x interface{}, opts *ApplyOptions) error {
+	return apply(context.Background(), data, x, opts, nil)
+}
+
+// ApplyWithOptionsContext applies a patch to x in the same way as
+// ApplyWithOptions and stops once ctx is done, as ApplyContext does.
+func ApplyWithOptionsContext(ctx context.Context, data []byte, x interface{}, opts *ApplyOptions) error {
+	return apply(ctx, data, x, opts, nil)
+}
+
+// check returns an error if any of the patches exceeds the limits or is not
+// permitted on values of type t.
+func (o *ApplyOptions) check(patches []Patch, t reflect.Type) error {
+	if o.MaxOperations > 0 && len(patches) > o.MaxOperations {
+		return &ErrLimit{Limit: "number of operations", Max: o.MaxOperations}
+	}
+	for _, p := range patches {
+		err := o.checkSize(&p)
+		if err != nil {
+			return err
+		}
+		write := p.Op != "test"
+		err = o.permit(p.Op, p.Path, write, t)
+		if err != nil {
+			return err
+		}
+		if p.From != "" {
+			err = o.permit(p.Op, p.From, p.Op == "move", t)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkSize returns an error if p exceeds the limits which do not depend on
+// the value being patched.
+func (o *ApplyOptions) checkSize(p *Patch) error {
+	if o.MaxPathDepth > 0 {
+		if len(segments(p.Path)) > o.MaxPathDepth {
+			return &ErrLimit{Limit: "path depth", Max: o.MaxPathDepth, Path: p.Path}
+		}
+		if len(segments(p.From)) > o.MaxPathDepth {
+			return &ErrLimit{Limit: "path depth", Max: o.MaxPathDepth, Path: p.From}
+		}
+	}
+	if o.MaxValueBytes > 0 && len(p.Value) > o.MaxValueBytes {
+		return &ErrLimit{Limit: "value size", Max: o.MaxValueBytes, Path: p.Path}
+	}
+	return nil
+}
+
+// checkLength returns an error if the operation on path made the slice or
+// map at or above it longer than allowed.
+func (o *ApplyOptions) checkLength(path string, x reflect.Value) error {
+	if o.MaxLength <= 0 {
+		return nil
+	}
+	child, _ := lookup(path, x)
+	for _, v := range []reflect.Value{indirect(parentOf(path, x)), indirect(child)} {
+		if (v.Kind() == reflect.Slice || v.Kind() == reflect.Map) && v.Len() > o.MaxLength {
+			return &ErrLimit{Limit: "length", Max: o.MaxLength, Path: "/" + path}
+		}
+	}
+	return nil
+}
+
+// checkDocument returns an error if the JSON encoding of x is larger than
+// allowed.
+func (o *ApplyOptions) checkDocument(x reflect.Value) error {
+	size, err := o.documentSize(x)
+	if err != nil {
+		return err
+	}
+	if size > o.MaxDocumentBytes {
+		return &ErrLimit{Limit: "document size", Max: o.MaxDocumentBytes}
+	}
+	return nil
+}
+
+// documentSize returns the size of the JSON encoding of x if the size is
+// limited.
+func (o *ApplyOptions) documentSize(x reflect.Value) (int, error) {
+	if o.MaxDocumentBytes <= 0 {
+		return 0, nil
+	}
+	data, err := json.Marshal(x.Interface())
+	if err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// checkGrowth returns an estimate of the size of the JSON encoding of x
+// after p was applied to it, given the size before. Only when the estimate
+// exceeds the limit is the document encoded, so that a patch doubling it
+// with each copy fails early without encoding it after every operation.
+func (o *ApplyOptions) checkGrowth(size int, p *Patch, x reflect.Value) (int, error) {
+	if o.MaxDocumentBytes <= 0 {
+		return 0, nil
+	}
+	switch p.Op {
+	case "add", "replace":
+		size += len(p.Value)
+	case "copy":
+		v, ok := lookup(strings.TrimPrefix(p.From, "/"), x)
+		if ok && v.IsValid() && v.CanInterface() {
+			data, err := json.Marshal(v.Interface())
+			if err != nil {
+				return 0, err
+			}
+			size += len(data)
+		}
+	}
+	if size <= o.MaxDocumentBytes {
+		return size, nil
+	}
+	size, err := o.documentSize(x)
+	if err != nil {
+		return 0, err
+	}
+	if size > o.MaxDocumentBytes {
+		return 0, &ErrLimit{Limit: "document size", Max: o.MaxDocumentBytes, Path: p.Path}
+	}
+	return size, nil
+}
+
+func (o *ApplyOptions) permit(op, path string, write bool, t reflect.Type) error {
+	nodes := segments(o.canonical(path, t))
+	if !permitTags(nodes, write, t) {
+		return &ErrPermission{Op: op, Path: path}
+	}
+	if !write {
+		return nil
+	}
+	for _, pattern := range o.Deny {
+		// writing a parent writes the denied location as well
+		denied := segments(o.canonical(pattern, t))
+		if match(denied, nodes) || contains(nodes, denied) {
+			return &ErrPermission{Op: op, Path: path}
+		}
+	}
+	if len(o.Allow) == 0 {
+		return nil
+	}
+	for _, pattern := range o.Allow {
+		if match(segments(o.canonical(pattern, t)), nodes) {
+			return nil
+		}
+	}
+	return &ErrPermission{Op: op, Path: path}
+}
+
+// canonical returns path with the segments referring to struct fields
+// replaced by the names of the fields, so that different spellings of the
+// same location compare equal. It returns path unchanged if it cannot be
+// resolved, in which case applying the operation fails later on.
+func (o *ApplyOptions) canonical(path string, t reflect.Type) string {
+	r := o.FieldResolver
+	if r == nil {
+		r = lenient{}
+	}
+	c, err := resolvePath(r, path, t)
+	if err != nil {
+		return path
+	}
+	return c
+}
+
+// resolve replaces the paths of the patches by the ones resolved by the
+// FieldResolver, if any.
+func (o *ApplyOptions) resolve(patches []Patch, t reflect.Type) error {
+	if o.FieldResolver == nil {
+		return nil
+	}
+	for i := range patches {
+		p := &patches[i]
+		var err error
+		p.Path, err = resolvePath(o.FieldResolver, p.Path, t)
+		if err != nil {
+			return err
+		}
+		if p.From != "" {
+			p.From, err = resolvePath(o.FieldResolver, p.From, t)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// permitTags reports whether the jsonpatch tags of the struct fields on the
+// way to the location permit accessing it. Neither is accessing a location
+// permitted if the tags of the fields below it forbid it.
+func permitTags(nodes []string, write bool, t reflect.Type) bool {
+	for _, node := range nodes {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			f, ok := t.FieldByName(bestMatch(node, t))
+			if !ok {
+				// applying the operation fails later on
+				return true
+			}
+			switch f.Tag.Get("jsonpatch") {
+			case "-":
+				return false
+			case "readonly":
+				if write {
+					return false
+				}
+			}
+			t = f.Type
+		case reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return true
+		}
+	}
+	return !tagged(t, write, map[reflect.Type]bool{})
+}
+
+// tagged reports whether values of type t contain struct fields tagged
+// `jsonpatch:"-"`, or with any jsonpatch tag if write is set. seen holds
+// the types already visited.
+func tagged(t reflect.Type, write bool, seen map[reflect.Type]bool) bool {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+	switch t.Kind() {
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			tag := f.Tag.Get("jsonpatch")
+			if tag == "-" || write && tag != "" || tagged(f.Type, write, seen) {
+				return true
+			}
+		}
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return tagged(t.Elem(), write, seen)
+	}
+	return false
+}
+
+// segments splits path into its segments the same way rapply does.
+func segments(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// match reports whether the location nodes is at or below the location
+// described by pattern.
+func match(pattern, nodes []string) bool {
+	if len(nodes) < len(pattern) {
+		return false
+	}
+	for i, s := range pattern {
+		if s != "*" && s != nodes[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// contains reports whether the location nodes is above a location
+// described by pattern.
+func contains(nodes, pattern []string) bool {
+	if len(nodes) >= len(pattern) {
+		return false
+	}
+	return match(pattern[:len(nodes)], nodes)
+}
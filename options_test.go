@@ -0,0 +1,317 @@
+package jsonpatch
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+type testAccount struct {
+	ID    string `json:"id" jsonpatch:"readonly"`
+	Role  string `json:"role"`
+	Name  string `json:"name"`
+	Token string `json:"token" jsonpatch:"-"`
+	Items []testItem
+}
+
+type testItem struct {
+	Name  string
+	Price int
+}
+
+func TestApplyWithOptionsTags(t *testing.T) {
+	a := testAccount{ID: "1", Token: "secret"}
+	tests := []string{
+		`[{"op": "replace", "path": "/id", "value": "2"}]`,
+		`[{"op": "test", "path": "/token", "value": "secret"}]`,
+		`[{"op": "test", "path": "", "value": {"token": "secret"}}]`,
+		`[{"op": "replace", "path": "/name", "value": "Calvin"},
+		  {"op": "remove", "path": "/token"}]`,
+	}
+	for _, p := range tests {
+		err := ApplyWithOptions([]byte(p), &a, &ApplyOptions{})
+		if _, ok := err.(*ErrPermission); !ok {
+			t.Fatal("expected permission error for", p, err)
+		}
+	}
+	if a.ID != "1" || a.Name != "" || a.Token != "secret" {
+		t.Fatal("rejected patch was applied", a)
+	}
+
+	p := []byte(`[{"op": "test", "path": "/id", "value": "1"}]`)
+	err := ApplyWithOptions(p, &a, &ApplyOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestApplyWithOptionsPatterns(t *testing.T) {
+	a := testAccount{
+		Items: []testItem{{Name: "a", Price: 1}, {Name: "b", Price: 2}},
+	}
+	opts := &ApplyOptions{
+		Allow: []string{"/name", "/items/*/price"},
+		Deny:  []string{"/items/1"},
+	}
+	tests := []struct {
+		patch   string
+		allowed bool
+	}{
+		{`[{"op": "replace", "path": "/name", "value": "Calvin"}]`, true},
+		{`[{"op": "replace", "path": "/role", "value": "admin"}]`, false},
+		{`[{"op": "replace", "path": "/items/0/price", "value": 3}]`, true},
+		{`[{"op": "replace", "path": "/items/0/name", "value": "c"}]`, false},
+		{`[{"op": "replace", "path": "/items/1/price", "value": 3}]`, false},
+		{`[{"op": "remove", "path": "/items/0"}]`, false},
+		{`[{"op": "test", "path": "/role", "value": ""}]`, true},
+	}
+	for _, test := range tests {
+		err := ApplyWithOptions([]byte(test.patch), &a, opts)
+		if test.allowed && err != nil {
+			t.Error(test.patch, err)
+		}
+		if _, ok := err.(*ErrPermission); !test.allowed && !ok {
+			t.Error("expected permission error for", test.patch, err)
+		}
+	}
+	if a.Name != "Calvin" || a.Items[0].Price != 3 || a.Items[1].Price != 2 {
+		t.Fatal("unexpected result", a)
+	}
+}
+
+type testGrant struct {
+	ID   int    `json:"id" jsonpatch:"readonly"`
+	Role string `json:"role"`
+}
+
+type testOwner struct {
+	Inner   testGrant  `json:"inner"`
+	Profile *testGrant `json:"profile"`
+	Items   []testItem `json:"items"`
+}
+
+func TestApplyWithOptionsParents(t *testing.T) {
+	o := testOwner{Inner: testGrant{ID: 1}, Items: []testItem{{Name: "a", Price: 1}}}
+	opts := &ApplyOptions{Deny: []string{"/items/*/price"}}
+	tests := []struct {
+		patch   string
+		allowed bool
+	}{
+		{`[{"op": "replace", "path": "/inner", "value": {"id": 99, "role": "admin"}}]`, false},
+		{`[{"op": "add", "path": "/profile", "value": {"id": 99, "role": "admin"}}]`, false},
+		{`[{"op": "remove", "path": "/inner"}]`, false},
+		{`[{"op": "replace", "path": "", "value": {}}]`, false},
+		{`[{"op": "replace", "path": "/items", "value": []}]`, false},
+		{`[{"op": "replace", "path": "/items/0", "value": {"Name": "b", "Price": 0}}]`, false},
+		{`[{"op": "add", "path": "/items/-", "value": {"Name": "b", "Price": 0}}]`, false},
+		{`[{"op": "test", "path": "/inner", "value": {"id": 1, "role": ""}}]`, true},
+		{`[{"op": "replace", "path": "/inner/role", "value": "user"}]`, true},
+		{`[{"op": "replace", "path": "/items/0/Name", "value": "c"}]`, true},
+	}
+	for _, test := range tests {
+		err := ApplyWithOptions([]byte(test.patch), &o, opts)
+		if test.allowed && err != nil {
+			t.Error(test.patch, err)
+		}
+		if _, ok := err.(*ErrPermission); !test.allowed && !ok {
+			t.Error("expected permission error for", test.patch, err)
+		}
+	}
+
+	err := ApplyMergePatch([]byte(`{"profile": {"id": 99, "role": "admin"}}`), &o, opts)
+	if _, ok := err.(*ErrPermission); !ok {
+		t.Error("expected permission error, got", err)
+	}
+	err = ApplyMergePatch([]byte(`{"inner": {"role": "admin"}}`), &o, opts)
+	if err != nil {
+		t.Error(err)
+	}
+	if o.Inner.ID != 1 || o.Inner.Role != "admin" || o.Profile != nil || o.Items[0].Price != 1 || o.Items[0].Name != "c" {
+		t.Fatal("unexpected result", o)
+	}
+}
+
+func TestApplyWithOptionsLimits(t *testing.T) {
+	tests := []struct {
+		opts  ApplyOptions
+		patch string
+	}{
+		{
+			ApplyOptions{MaxOperations: 1},
+			`[{"op": "add", "path": "/name", "value": "a"},
+			  {"op": "add", "path": "/email", "value": "b"}]`,
+		},
+		{
+			ApplyOptions{MaxPathDepth: 1},
+			`[{"op": "add", "path": "/child/name", "value": "a"}]`,
+		},
+		{
+			ApplyOptions{MaxValueBytes: 4},
+			`[{"op": "add", "path": "/name", "value": "Calvin"}]`,
+		},
+		{
+			ApplyOptions{MaxLength: 2},
+			`[{"op": "add", "path": "/phones/-", "value": "1"},
+			  {"op": "add", "path": "/phones/-", "value": "2"},
+			  {"op": "add", "path": "/phones/-", "value": "3"}]`,
+		},
+		{
+			ApplyOptions{MaxLength: 2},
+			`[{"op": "replace", "path": "/phones", "value": ["1", "2", "3"]}]`,
+		},
+		{
+			ApplyOptions{MaxDocumentBytes: 64},
+			`[{"op": "add", "path": "/name", "value": "Calvin"}]`,
+		},
+	}
+	for _, test := range tests {
+		var u testUser
+		err := ApplyWithOptions([]byte(test.patch), &u, &test.opts)
+		if _, ok := err.(*ErrLimit); !ok {
+			t.Error("expected limit error for", test.patch, err)
+		}
+		if u.Name != "" || len(u.Phones) != 0 {
+			t.Error("patch exceeding the limits was applied", u)
+		}
+	}
+
+	var u testUser
+	opts := &ApplyOptions{
+		MaxOperations:    2,
+		MaxPathDepth:     2,
+		MaxValueBytes:    8,
+		MaxLength:        2,
+		MaxDocumentBytes: 256,
+	}
+	p := []byte(`[
+		{"op": "add", "path": "/phones/-", "value": "1"},
+		{"op": "add", "path": "/child/name", "value": "Susie"}
+	]`)
+	err := ApplyWithOptions(p, &u, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+type testRange struct {
+	Min int
+	Max int
+}
+
+func (r *testRange) Validate() error {
+	if r.Min > r.Max {
+		return errors.New("min greater than max")
+	}
+	return nil
+}
+
+func TestApplyWithOptionsGrowth(t *testing.T) {
+	var ops []string
+	for i := 0; i < 30; i++ {
+		ops = append(ops, `{"op": "copy", "from": "", "path": "/b"}`, `{"op": "copy", "from": "", "path": "/c"}`)
+	}
+	p := []byte("[" + strings.Join(ops, ",") + "]")
+	doc := map[string]interface{}{"a": "calvin"}
+	applied := 0
+	opts := &ApplyOptions{
+		MaxDocumentBytes: 1024,
+		BeforeOp:         func(Patch) error { applied++; return nil },
+	}
+	err := ApplyWithOptions(p, &doc, opts)
+	if _, ok := err.(*ErrLimit); !ok {
+		t.Fatal("expected limit error, got", err)
+	}
+	if applied > 10 {
+		t.Fatalf("limit exceeded after %d operations", applied)
+	}
+}
+
+func TestApplyWithOptionsContext(t *testing.T) {
+	a := testAccount{Name: "hobbes"}
+	opts := &ApplyOptions{Deny: []string{"/role"}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := ApplyWithOptionsContext(ctx, []byte(`[{"op": "replace", "path": "/name", "value": "Calvin"}]`), &a, opts)
+	if err != context.Canceled || a.Name != "hobbes" {
+		t.Fatal("expected context.Canceled, got", err, a)
+	}
+	err = ApplyMergePatchContext(ctx, []byte(`{"name": "Calvin"}`), &a, opts)
+	if err != context.Canceled || a.Name != "hobbes" {
+		t.Fatal("expected context.Canceled, got", err, a)
+	}
+
+	err = ApplyWithOptionsContext(context.Background(), []byte(`[{"op": "replace", "path": "/role", "value": "boy"}]`), &a, opts)
+	if _, ok := err.(*ErrPermission); !ok {
+		t.Fatal("expected ErrPermission, got", err)
+	}
+}
+
+func TestApplyWithOptionsHooks(t *testing.T) {
+	u := testUser{Name: "hobbes"}
+	var before []string
+	var after []Change
+	opts := &ApplyOptions{
+		BeforeOp: func(p Patch) error {
+			before = append(before, p.Op+" "+p.Path)
+			return nil
+		},
+		AfterOp: func(c Change) error {
+			after = append(after, c)
+			return nil
+		},
+		Validate: func(x interface{}) error {
+			if x.(*testUser).Age < 0 {
+				return errors.New("negative age")
+			}
+			return nil
+		},
+	}
+	p := []byte(`[
+		{"op": "test", "path": "/name", "value": "hobbes"},
+		{"op": "replace", "path": "/name", "value": "Calvin"}
+	]`)
+	err := ApplyWithOptions(p, &u, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(before) != 2 || before[1] != "replace /name" {
+		t.Fatal("BeforeOp not called", before)
+	}
+	if len(after) != 2 || after[1].Old != "hobbes" || after[1].New != "Calvin" {
+		t.Fatal("AfterOp not called", after)
+	}
+
+	p = []byte(`[{"op": "replace", "path": "/age", "value": -1}]`)
+	err = ApplyWithOptions(p, &u, opts)
+	if _, ok := err.(*ErrValidation); !ok {
+		t.Fatal("expected validation error, got", err)
+	}
+	if u.Age != 0 {
+		t.Fatal("invalid result was committed", u)
+	}
+
+	opts.AfterOp = func(c Change) error {
+		return errors.New("rejected")
+	}
+	p = []byte(`[{"op": "replace", "path": "/age", "value": 6}]`)
+	err = ApplyWithOptions(p, &u, opts)
+	if err == nil || u.Age != 0 {
+		t.Fatal("AfterOp error did not abort the patch", u)
+	}
+}
+
+func TestApplyValidator(t *testing.T) {
+	r := testRange{Min: 1, Max: 2}
+	err := Apply([]byte(`[{"op": "replace", "path": "/min", "value": 3}]`), &r)
+	if _, ok := err.(*ErrValidation); !ok {
+		t.Fatal("expected validation error, got", err)
+	}
+	if r.Min != 1 {
+		t.Fatal("invalid result was committed", r)
+	}
+	err = Apply([]byte(`[{"op": "replace", "path": "/max", "value": 3}]`), &r)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,169 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrNotFound is returned when a segment of a JSON Pointer does not exist.
+type ErrNotFound struct {
+	// Pointer is the part of the pointer up to the missing segment.
+	Pointer string
+	Segment string
+}
+
+func (e *ErrNotFound) Error() string {
+	return fmt.Sprintf("jsonpatch: %s not found", e.Pointer)
+}
+
+// ErrInvalidPointer is returned for a JSON Pointer which is neither empty
+// nor starts with a slash.
+type ErrInvalidPointer struct {
+	Pointer string
+}
+
+func (e *ErrInvalidPointer) Error() string {
+	return fmt.Sprintf("jsonpatch: invalid JSON Pointer %q", e.Pointer)
+}
+
+// parsePointer returns the path of the JSON Pointer as used by rapply,
+// i.e. without the leading slash, and its unescaped segments. Unlike for
+// walk, the path "" of the pointer "/" has the segment "".
+func parsePointer(pointer string) (string, []string, error) {
+	if pointer == "" {
+		return "", nil, nil
+	}
+	if pointer[0] != '/' {
+		return "", nil, &ErrInvalidPointer{pointer}
+	}
+	path := pointer[1:]
+	nodes := strings.Split(path, "/")
+	for i, node := range nodes {
+		nodes[i] = unescape(node)
+	}
+	return path, nodes, nil
+}
+
+// Get returns the value at the JSON Pointer in x. Struct fields are matched
+// in the same way as by Apply.
+func Get(x interface{}, pointer string) (interface{}, error) {
+	_, nodes, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	v, err := resolveNodes(nodes, reflect.ValueOf(x))
+	if err != nil {
+		return nil, err
+	}
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if !v.CanInterface() {
+		return nil, &ErrUnsupported{pointer}
+	}
+	return v.Interface(), nil
+}
+
+// Has reports whether the JSON Pointer exists in x.
+func Has(x interface{}, pointer string) bool {
+	_, nodes, err := parsePointer(pointer)
+	if err != nil {
+		return false
+	}
+	_, err = resolveNodes(nodes, reflect.ValueOf(x))
+	return err == nil
+}
+
+// Set sets the value at the JSON Pointer in x, which must be a pointer, to
+// value, converted through its JSON encoding. Elements of slices are
+// replaced, and appended if the last segment is "-", and keys missing from
+// maps are added. The parent of the location must exist.
+func Set(x interface{}, pointer string, value interface{}) error {
+	rx := reflect.ValueOf(x)
+	if rx.Kind() != reflect.Ptr || rx.IsNil() {
+		return ErrNonPointer
+	}
+	path, nodes, err := parsePointer(pointer)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		v := reflect.New(rx.Elem().Type())
+		err = json.Unmarshal(data, v.Interface())
+		if err != nil {
+			return err
+		}
+		rx.Elem().Set(v.Elem())
+		return nil
+	}
+	parent, err := resolveNodes(nodes[:len(nodes)-1], rx)
+	if err != nil {
+		return err
+	}
+	op := "replace"
+	if _, err = resolveNodes(nodes, rx); err != nil {
+		if !canHold(parent, nodes[len(nodes)-1]) {
+			return notFound(nodes, len(nodes)-1)
+		}
+		op = "add"
+	}
+	return rapply(path, &Patch{Op: op, Path: pointer, Value: data}, rx)
+}
+
+// canHold reports whether the segment can be added to x. Struct fields must
+// exist, and nil pointers are allocated by rapply, so they are checked by
+// their type.
+func canHold(x reflect.Value, segment string) bool {
+	if containerOf(x) != nil {
+		return true
+	}
+	var t reflect.Type
+	if v := indirect(x); v.IsValid() {
+		t = v.Type()
+	} else if x.Kind() == reflect.Ptr {
+		t = x.Type()
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	} else {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return true
+	case reflect.Map:
+		return t.Key().Kind() == reflect.String
+	case reflect.Struct:
+		return bestMatch(segment, t) != ""
+	}
+	return false
+}
+
+// Delete removes the value at the JSON Pointer from x, which must be a
+// pointer. Struct fields are set to their zero value, and so is x for the
+// empty pointer.
+func Delete(x interface{}, pointer string) error {
+	rx := reflect.ValueOf(x)
+	if rx.Kind() != reflect.Ptr || rx.IsNil() {
+		return ErrNonPointer
+	}
+	path, nodes, err := parsePointer(pointer)
+	if err != nil {
+		return err
+	}
+	if len(nodes) == 0 {
+		rx.Elem().Set(reflect.Zero(rx.Elem().Type()))
+		return nil
+	}
+	_, err = resolveNodes(nodes, rx)
+	if err != nil {
+		return err
+	}
+	return rapply(path, &Patch{Op: "remove", Path: pointer}, rx)
+}
@@ -0,0 +1,154 @@
+package jsonpatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetSetHasDelete(t *testing.T) {
+	u := testUser{
+		Name:   "Calvin",
+		Child:  &testUser{Name: "Hobbes"},
+		Phones: []string{"1", "2"},
+		M:      map[string]string{"a": "b"},
+	}
+
+	gets := map[string]interface{}{
+		"/Name":        "Calvin",
+		"/child/Name":  "Hobbes",
+		"/Phones/1":    "2",
+		"/M/a":         "b",
+		"/Child/Child": (*testUser)(nil),
+	}
+	for pointer, expected := range gets {
+		v, err := Get(&u, pointer)
+		if err != nil || !reflect.DeepEqual(v, expected) {
+			t.Errorf("%s: expected %v, got %v %v", pointer, expected, v, err)
+		}
+		if !Has(u, pointer) {
+			t.Errorf("%s: expected to exist", pointer)
+		}
+	}
+
+	missing := map[string]string{
+		"/Missing":          "/Missing",
+		"/Phones/2":         "/Phones/2",
+		"/M/b":              "/M/b",
+		"/Child/Child/Name": "/Child/Child/Name",
+		"/Child/Phones/0/x": "/Child/Phones/0",
+		"/Name/x":           "/Name/x",
+	}
+	for pointer, expected := range missing {
+		_, err := Get(&u, pointer)
+		e, ok := err.(*ErrNotFound)
+		if !ok || e.Pointer != expected {
+			t.Errorf("%s: expected %s not to be found, got %v", pointer, expected, err)
+		}
+		if Has(&u, pointer) {
+			t.Errorf("%s: expected not to exist", pointer)
+		}
+	}
+
+	sets := []struct {
+		pointer string
+		value   interface{}
+	}{
+		{"/Name", "Susie"},
+		{"/Age", 6},
+		{"/Phones/0", "3"},
+		{"/Phones/-", "4"},
+		{"/M/c", "d"},
+		{"/Child/Child", testUser{Name: "Moe"}},
+	}
+	for _, s := range sets {
+		err := Set(&u, s.pointer, s.value)
+		if err != nil {
+			t.Fatal(s.pointer, err)
+		}
+	}
+	expected := testUser{
+		Name:   "Susie",
+		Age:    6,
+		Child:  &testUser{Name: "Hobbes", Child: &testUser{Name: "Moe"}},
+		Phones: []string{"3", "2", "4"},
+		M:      map[string]string{"a": "b", "c": "d"},
+	}
+	if !reflect.DeepEqual(u, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, u)
+	}
+	err := Set(&u, "/Child/Child/Child/Child/Name", "x")
+	if e, ok := err.(*ErrNotFound); !ok || e.Segment != "Child" {
+		t.Fatal("expected ErrNotFound, got", err)
+	}
+	if Set(u, "/Name", "x") != ErrNonPointer {
+		t.Fatal("expected ErrNonPointer")
+	}
+	for pointer, segment := range map[string]string{"/nope": "nope", "/Name/x": "x", "/Age/0": "0"} {
+		err = Set(&u, pointer, "x")
+		if e, ok := err.(*ErrNotFound); !ok || e.Pointer != pointer || e.Segment != segment {
+			t.Errorf("%s: expected ErrNotFound, got %v", pointer, err)
+		}
+	}
+	if u.Name != "Susie" {
+		t.Fatal("unexpected result", u)
+	}
+
+	for _, pointer := range []string{"/Phones/0", "/M/a", "/Child"} {
+		err = Delete(&u, pointer)
+		if err != nil {
+			t.Fatal(pointer, err)
+		}
+	}
+	if u.Child != nil || !reflect.DeepEqual(u.Phones, []string{"2", "4"}) || !reflect.DeepEqual(u.M, map[string]string{"c": "d"}) {
+		t.Fatalf("unexpected result %+v", u)
+	}
+	if _, ok := Delete(&u, "/M/a").(*ErrNotFound); !ok {
+		t.Fatal("expected ErrNotFound")
+	}
+	err = Delete(&u, "")
+	if err != nil || !reflect.DeepEqual(u, testUser{}) {
+		t.Fatalf("expected the zero value, got %+v %v", u, err)
+	}
+}
+
+func TestPointerEscaping(t *testing.T) {
+	doc := map[string]interface{}{
+		"a/b": 1.0,
+		"m~n": 2.0,
+		"~1":  3.0,
+		"":    map[string]interface{}{"name": 4.0},
+	}
+	gets := map[string]interface{}{
+		"/a~1b":  1.0,
+		"/m~0n":  2.0,
+		"/~01":   3.0,
+		"//name": 4.0,
+	}
+	for pointer, expected := range gets {
+		v, err := Get(doc, pointer)
+		if err != nil || v != expected {
+			t.Errorf("%s: expected %v, got %v %v", pointer, expected, v, err)
+		}
+	}
+	if Has(doc, "/name") {
+		t.Error("/name: expected not to exist")
+	}
+
+	for _, pointer := range []string{"a~1b", "name/"} {
+		if _, ok := Set(&doc, pointer, 5).(*ErrInvalidPointer); !ok {
+			t.Errorf("%s: expected ErrInvalidPointer", pointer)
+		}
+	}
+	err := Set(&doc, "/x~1y", 5)
+	if err != nil || doc["x/y"] != 5.0 {
+		t.Fatal("unexpected result", doc, err)
+	}
+	err = Set(&doc, "//name", 6)
+	if err != nil || doc[""].(map[string]interface{})["name"] != 6.0 {
+		t.Fatal("unexpected result", doc, err)
+	}
+	err = Delete(&doc, "/m~0n")
+	if _, ok := doc["m~n"]; err != nil || ok {
+		t.Fatal("unexpected result", doc, err)
+	}
+}
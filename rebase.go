@@ -0,0 +1,114 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ErrPrefix is returned by Extract for an operation which affects the
+// location at Prefix but cannot be made relative to it, e.g. a move into it
+// from outside or a replace of one of its parents.
+type ErrPrefix struct {
+	Patch  Patch
+	Prefix string
+}
+
+func (e *ErrPrefix) Error() string {
+	return fmt.Sprintf("jsonpatch: %s %s cannot be made relative to %s", e.Patch.Op, e.Patch.Path, e.Prefix)
+}
+
+// Rebase returns the patches with prefix, a JSON Pointer such as
+// "/items/3", prepended to their paths. It turns a patch of a sub-document
+// into a patch of the document embedding it at prefix.
+func Rebase(patches []Patch, prefix string) []Patch {
+	prefix = pointer(prefix)
+	rebased := make([]Patch, len(patches))
+	for i, p := range patches {
+		p.Path = prefix + p.Path
+		if p.From != "" {
+			p.From = prefix + p.From
+		}
+		rebased[i] = p
+	}
+	return rebased
+}
+
+// Extract returns the operations of patches which affect the location at
+// prefix, with their paths made relative to it, so they can be applied to
+// the sub-document at prefix. It is the reverse of Rebase. Operations on
+// other locations are left out.
+//
+// It returns ErrPrefix for operations which cannot be expressed relative to
+// prefix.
+func Extract(patches []Patch, prefix string) ([]Patch, error) {
+	prefix = pointer(prefix)
+	var extracted []Patch
+	for _, p := range patches {
+		path, in := relative(p.Path, prefix)
+		switch {
+		case in:
+			if p.Op == "copy" || p.Op == "move" {
+				from, ok := relative(p.From, prefix)
+				if !ok {
+					return nil, &ErrPrefix{p, prefix}
+				}
+				p.From = from
+			}
+			p.Path = path
+			extracted = append(extracted, p)
+		case affects(p.Path, prefix):
+			// tests of a parent cannot be narrowed down to the location
+			if p.Op != "test" {
+				return nil, &ErrPrefix{p, prefix}
+			}
+		case p.Op == "move":
+			// the source of a move is removed
+			_, from := relative(p.From, prefix)
+			if from || affects(p.From, prefix) {
+				return nil, &ErrPrefix{p, prefix}
+			}
+		}
+	}
+	return extracted, nil
+}
+
+// Filter returns the operations of patches for which keep returns true.
+func Filter(patches []Patch, keep func(p Patch) bool) []Patch {
+	var filtered []Patch
+	for _, p := range patches {
+		if keep(p) {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered
+}
+
+// pointer returns path as a JSON Pointer, i.e. starting with a slash unless
+// it is the empty pointer to the whole document.
+func pointer(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// relative returns path relative to prefix. The second return value is
+// false if path is not at or below prefix.
+func relative(path, prefix string) (string, bool) {
+	path = pointer(path)
+	if path == prefix {
+		return "", true
+	}
+	if strings.HasPrefix(path, prefix+"/") {
+		return path[len(prefix):], true
+	}
+	return "", false
+}
+
+// affects reports whether modifying the location at path affects the one
+// at prefix, i.e. whether path is prefix or one of its parents.
+func affects(path, prefix string) bool {
+	_, ok := relative(prefix, pointer(path))
+	return ok
+}
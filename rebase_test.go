@@ -0,0 +1,107 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRebase(t *testing.T) {
+	var patches []Patch
+	err := json.Unmarshal([]byte(`[
+		{"op": "replace", "path": "/Name", "value": "a"},
+		{"op": "move", "from": "/Tags/0", "path": "/Tags/1"},
+		{"op": "remove", "path": ""}
+	]`), &patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rebased := Rebase(patches, "/Items/3/")
+	paths := []string{"/Items/3/Name", "/Items/3/Tags/1", "/Items/3"}
+	for i, p := range rebased {
+		if p.Path != paths[i] {
+			t.Fatalf("expected %s, got %s", paths[i], p.Path)
+		}
+	}
+	if rebased[0].From != "" || rebased[1].From != "/Items/3/Tags/0" || patches[0].Path != "/Name" {
+		t.Fatal("unexpected patches", rebased, patches)
+	}
+
+	extracted, err := Extract(rebased, "Items/3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range patches {
+		patches[i].Path = pointer(patches[i].Path)
+	}
+	if !reflect.DeepEqual(extracted, patches) {
+		t.Fatalf("expected %v, got %v", patches, extracted)
+	}
+	if !reflect.DeepEqual(Rebase(patches, ""), patches) {
+		t.Fatal("empty prefix changed the patches")
+	}
+}
+
+func TestExtract(t *testing.T) {
+	tests := []struct {
+		patch    string
+		expected string
+		err      bool
+	}{
+		{`[{"op": "add", "path": "/Items/3/Name", "value": "a"}, {"op": "add", "path": "/Items/30/Name", "value": "b"}, {"op": "remove", "path": "/Name"}]`,
+			`[{"op": "add", "path": "/Name", "value": "a"}]`, false},
+		{`[{"op": "test", "path": "/Items", "value": []}, {"op": "replace", "path": "/Items/3", "value": {}}]`,
+			`[{"op": "replace", "path": "", "value": {}}]`, false},
+		{`[{"op": "copy", "from": "/Name", "path": "/Tags/0"}, {"op": "copy", "from": "/Items/3/Name", "path": "/Name"}]`,
+			`null`, false},
+		{`[{"op": "move", "from": "/Items/3/Tags/0", "path": "/Items/3/Tags/1"}]`,
+			`[{"op": "move", "from": "/Tags/0", "path": "/Tags/1"}]`, false},
+		{`[{"op": "remove", "path": "/Items"}]`, ``, true},
+		{`[{"op": "copy", "from": "/Name", "path": "/Items/3/Name"}]`, ``, true},
+		{`[{"op": "move", "from": "/Items/3/Name", "path": "/Name"}]`, ``, true},
+		{`[{"op": "move", "from": "/Items", "path": "/Other"}]`, ``, true},
+	}
+	for _, tt := range tests {
+		var patches []Patch
+		err := json.Unmarshal([]byte(tt.patch), &patches)
+		if err != nil {
+			t.Fatal(err)
+		}
+		extracted, err := Extract(patches, "/Items/3")
+		if tt.err {
+			if _, ok := err.(*ErrPrefix); !ok {
+				t.Errorf("%s: expected ErrPrefix, got %v", tt.patch, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.patch, err)
+			continue
+		}
+		var expected []Patch
+		err = json.Unmarshal([]byte(tt.expected), &expected)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(extracted, expected) {
+			t.Errorf("%s: expected %v, got %v", tt.patch, expected, extracted)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	var patches []Patch
+	err := json.Unmarshal([]byte(`[
+		{"op": "replace", "path": "/Name", "value": "a"},
+		{"op": "add", "path": "/Tags/-", "value": "b"},
+		{"op": "remove", "path": "/Tags/0"}
+	]`), &patches)
+	if err != nil {
+		t.Fatal(err)
+	}
+	filtered := Filter(patches, func(p Patch) bool { return strings.HasPrefix(p.Path, "/Tags/") })
+	if !reflect.DeepEqual(filtered, patches[1:]) {
+		t.Fatal("unexpected operations", filtered)
+	}
+}
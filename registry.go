@@ -0,0 +1,62 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// OperationFunc implements a custom patch operation. parent is a non-nil
+// pointer to the value holding the location the path of p points to and
+// key is the last segment of the path.
+//
+// The operation is applied to the copy Apply works on, so returning an
+// error discards all of the changes made by the patch.
+type OperationFunc func(parent reflect.Value, key string, p *Patch) error
+
+var (
+	operationsMu sync.RWMutex
+	operations   = make(map[string]OperationFunc)
+)
+
+// builtin lists the operations defined by RFC 6902.
+var builtin = map[string]bool{
+	"add":     true,
+	"remove":  true,
+	"replace": true,
+	"move":    true,
+	"copy":    true,
+	"test":    true,
+}
+
+// ErrUnknownOp is returned for operations which are neither defined by
+// RFC 6902 nor registered.
+type ErrUnknownOp struct {
+	Op string
+}
+
+func (e *ErrUnknownOp) Error() string {
+	return fmt.Sprintf("jsonpatch: unknown operation %q", e.Op)
+}
+
+// RegisterOperation makes a custom operation available to all patches under
+// the given name. It panics if fn is nil or the name is already used by
+// another operation.
+func RegisterOperation(name string, fn OperationFunc) {
+	operationsMu.Lock()
+	defer operationsMu.Unlock()
+	if fn == nil {
+		panic("jsonpatch: RegisterOperation function is nil")
+	}
+	if _, dup := operations[name]; dup || builtin[name] {
+		panic("jsonpatch: RegisterOperation called twice for operation " + name)
+	}
+	operations[name] = fn
+}
+
+// operation returns the custom operation registered under name, or nil.
+func operation(name string) OperationFunc {
+	operationsMu.RLock()
+	defer operationsMu.RUnlock()
+	return operations[name]
+}
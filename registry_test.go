@@ -0,0 +1,85 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func init() {
+	RegisterOperation("increment", func(parent reflect.Value, key string, p *Patch) error {
+		m := parent.Elem()
+		if m.Kind() != reflect.Map {
+			return errors.New("increment: not a map")
+		}
+		var n int
+		err := json.Unmarshal(p.Value, &n)
+		if err != nil {
+			return err
+		}
+		k := reflect.ValueOf(key)
+		old := m.MapIndex(k)
+		if old.IsValid() {
+			n += int(old.Int())
+		}
+		m.SetMapIndex(k, reflect.ValueOf(n))
+		return nil
+	})
+}
+
+type testCounters struct {
+	Counts map[string]int
+}
+
+func TestRegisterOperation(t *testing.T) {
+	c := testCounters{Counts: map[string]int{"a": 1}}
+	p := []byte(`[
+		{"op": "increment", "path": "/counts/a", "value": 2},
+		{"op": "increment", "path": "/counts/b", "value": 1}
+	]`)
+	inverse, err := ApplyWithInverse(p, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Counts["a"] != 3 || c.Counts["b"] != 1 {
+		t.Fatal("operation not applied", c.Counts)
+	}
+
+	err = Apply(inverse, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c.Counts, map[string]int{"a": 1}) {
+		t.Fatal("inverse did not restore the counters", c.Counts)
+	}
+
+	p = []byte(`[
+		{"op": "increment", "path": "/counts/a", "value": 2},
+		{"op": "increment", "path": "/counts", "value": 1}
+	]`)
+	err = Apply(p, &c)
+	if err == nil {
+		t.Fatal("was supposed to fail")
+	}
+	if c.Counts["a"] != 1 {
+		t.Fatal("failed patch was applied", c.Counts)
+	}
+}
+
+func TestRegisterOperationTwice(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("registering a built-in operation did not panic")
+		}
+	}()
+	RegisterOperation("add", func(reflect.Value, string, *Patch) error { return nil })
+}
+
+func TestUnknownOperation(t *testing.T) {
+	var u testUser
+	err := Apply([]byte(`[{"op": "frobnicate", "path": "/name"}]`), &u)
+	if _, ok := err.(*ErrUnknownOp); !ok {
+		t.Fatal("expected unknown operation error, got", err)
+	}
+}
@@ -0,0 +1,164 @@
+package jsonpatch
+
+import (
+	"context"
+	"reflect"
+	"strings"
+
+	"github.com/optiopay/jsonpatch/deep"
+)
+
+// Change describes the effect of a single operation of a patch.
+type Change struct {
+	Op string
+	// Pointer is the JSON Pointer of the changed location. A trailing "-"
+	// is replaced by the index the element was appended at.
+	Pointer string
+	// Field is the Go path of the changed location, e.g. User.Address.City.
+	// Slice indices and map keys are written in brackets.
+	Field string
+	// Index holds the indices of the struct fields on the way to the
+	// changed location, see reflect.Value.FieldByIndex.
+	Index []int
+	// Old is the value before the operation, nil if it did not exist.
+	Old interface{}
+	// New is the value after the operation, nil if it was removed.
+	New interface{}
+}
+
+// ApplyReport applies a patch to x in the same way as Apply and returns the
+// changes made by each of its operations in order. Test operations do not
+// change anything and are not reported. A move makes two changes: the first
+// one to the location the value is moved from, the second one to the
+// location it is moved to.
+func ApplyReport(data []byte, x interface{}) ([]Change, error) {
+	t := &tracker{report: true}
+	err := apply(context.Background(), data, x, nil, t)
+	if err != nil {
+		return nil, err
+	}
+	return t.changes, nil
+}
+
+// describe returns the changes p is going to make. It has to be called
+// before p is applied to x.
+func describe(path string, p *Patch, x reflect.Value) ([]Change, error) {
+	var changes []Change
+	if p.Op == "move" {
+		from := strings.TrimPrefix(p.From, "/")
+		c, err := describeAt(from, p, x)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+		path = resolveMoveEnd(path, from, x)
+	} else {
+		path = resolveEnd(path, x)
+	}
+	c, err := describeAt(path, p, x)
+	if err != nil {
+		return nil, err
+	}
+	if p.Path == "" {
+		c.Pointer = ""
+	}
+	return append(changes, c), nil
+}
+
+// describeAt returns the change p is going to make to the location path.
+func describeAt(path string, p *Patch, x reflect.Value) (Change, error) {
+	field, index := fieldPath(path, x.Type())
+	c := Change{
+		Op:      p.Op,
+		Pointer: "/" + path,
+		Field:   field,
+		Index:   index,
+	}
+	parent := indirect(parentOf(path, x))
+	insert := p.Op == "add" || p.Op == "copy" || (p.Op == "move" && path != strings.TrimPrefix(p.From, "/"))
+	if insert && parent.Kind() == reflect.Slice {
+		// the element is inserted, nothing gets overwritten
+		return c, nil
+	}
+	old, ok := lookup(path, x)
+	if !ok {
+		return c, nil
+	}
+	var err error
+	c.Old, err = clone(old)
+	return c, err
+}
+
+// update fills in the new value of the location once the operation has
+// been applied to x. removed tells whether the value was taken away from
+// the location, as by a remove or from the source of a move.
+func (c *Change) update(x reflect.Value, removed bool) error {
+	path := strings.TrimPrefix(c.Pointer, "/")
+	parent := indirect(parentOf(path, x))
+	if removed && parent.Kind() == reflect.Slice {
+		// the following elements moved into the place
+		return nil
+	}
+	v, ok := lookup(path, x)
+	if !ok {
+		return nil
+	}
+	var err error
+	c.New, err = clone(v)
+	return err
+}
+
+// fieldPath returns the Go path and the struct field indices of the
+// location path points to within values of type t.
+func fieldPath(path string, t reflect.Type) (string, []int) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	field := t.Name()
+	var index []int
+	for _, node := range strings.Split(path, "/") {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			if f, ok := t.FieldByName(bestMatch(node, t)); ok {
+				if field != "" {
+					field += "."
+				}
+				field += f.Name
+				index = append(index, f.Index...)
+				t = f.Type
+				continue
+			}
+		case reflect.Slice, reflect.Array, reflect.Map:
+			field += "[" + node + "]"
+			t = t.Elem()
+			continue
+		}
+		// The type is not known until run time, thus neither are the
+		// types of the following segments.
+		field += "[" + node + "]"
+		t = reflect.TypeOf((*interface{})(nil)).Elem()
+	}
+	return field, index
+}
+
+// clone returns a deep copy of v so that the following operations do not
+// alter it.
+func clone(v reflect.Value) (interface{}, error) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if v.IsNil() {
+			return v.Interface(), nil
+		}
+	}
+	x := reflect.New(v.Type())
+	x.Elem().Set(v)
+	y := reflect.New(v.Type())
+	err := deep.Copy(x.Interface(), y.Interface())
+	if err != nil {
+		return nil, err
+	}
+	return y.Elem().Interface(), nil
+}
@@ -0,0 +1,120 @@
+package jsonpatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testAddress struct {
+	Street string
+	City   string `json:"city"`
+}
+
+type testCustomer struct {
+	Name    string
+	Address testAddress `json:"address"`
+	Tags    []string
+}
+
+func TestApplyReport(t *testing.T) {
+	c := testCustomer{
+		Name:    "Calvin",
+		Address: testAddress{City: "Berlin"},
+		Tags:    []string{"a", "b"},
+	}
+	p := []byte(`[
+		{"op": "replace", "path": "/address/city", "value": "Amsterdam"},
+		{"op": "test", "path": "/name", "value": "Calvin"},
+		{"op": "add", "path": "/tags/-", "value": "c"},
+		{"op": "remove", "path": "/tags/0"},
+		{"op": "remove", "path": "/name"},
+		{"op": "move", "from": "/tags/0", "path": "/tags/-"},
+		{"op": "move", "from": "/tags/1", "path": "/name"}
+	]`)
+	changes, err := ApplyReport(p, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Change{
+		{
+			Op:      "replace",
+			Pointer: "/address/city",
+			Field:   "testCustomer.Address.City",
+			Index:   []int{1, 1},
+			Old:     "Berlin",
+			New:     "Amsterdam",
+		},
+		{
+			Op:      "add",
+			Pointer: "/tags/2",
+			Field:   "testCustomer.Tags[2]",
+			Index:   []int{2},
+			New:     "c",
+		},
+		{
+			Op:      "remove",
+			Pointer: "/tags/0",
+			Field:   "testCustomer.Tags[0]",
+			Index:   []int{2},
+			Old:     "a",
+		},
+		{
+			Op:      "remove",
+			Pointer: "/name",
+			Field:   "testCustomer.Name",
+			Index:   []int{0},
+			Old:     "Calvin",
+			New:     "",
+		},
+		{
+			Op:      "move",
+			Pointer: "/tags/0",
+			Field:   "testCustomer.Tags[0]",
+			Index:   []int{2},
+			Old:     "b",
+		},
+		{
+			Op:      "move",
+			Pointer: "/tags/1",
+			Field:   "testCustomer.Tags[1]",
+			Index:   []int{2},
+			New:     "b",
+		},
+		{
+			Op:      "move",
+			Pointer: "/tags/1",
+			Field:   "testCustomer.Tags[1]",
+			Index:   []int{2},
+			Old:     "b",
+		},
+		{
+			Op:      "move",
+			Pointer: "/name",
+			Field:   "testCustomer.Name",
+			Index:   []int{0},
+			Old:     "",
+			New:     "b",
+		},
+	}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Fatalf("unexpected changes\n%+v\nexpected\n%+v", changes, expected)
+	}
+}
+
+func TestApplyReportValuesAreCopied(t *testing.T) {
+	u := testUser{Phones: []string{"a"}}
+	p := []byte(`[
+		{"op": "replace", "path": "/phones", "value": ["b"]},
+		{"op": "replace", "path": "/phones/0", "value": "c"}
+	]`)
+	changes, err := ApplyReport(p, &u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 2 {
+		t.Fatal(changes)
+	}
+	if !reflect.DeepEqual(changes[0].New, []string{"b"}) {
+		t.Fatal("value altered by the following operation", changes[0].New)
+	}
+}
@@ -0,0 +1,199 @@
+package jsonpatch
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// FieldResolver resolves the segments of paths which refer to struct
+// fields.
+type FieldResolver interface {
+	// ResolveField returns the name of the field of the struct type t the
+	// path segment name refers to.
+	ResolveField(t reflect.Type, name string) (string, error)
+}
+
+// ErrAmbiguousField is returned when a path segment refers to more than one
+// field of a struct.
+type ErrAmbiguousField struct {
+	Name   string
+	Type   string
+	Fields []string
+}
+
+func (e *ErrAmbiguousField) Error() string {
+	return fmt.Sprintf("jsonpatch: %s is ambiguous in %s, it matches %s", e.Name, e.Type, strings.Join(e.Fields, ", "))
+}
+
+// ErrUnknownField is returned by the built-in resolvers when a path segment
+// refers to none of the fields of a struct.
+type ErrUnknownField struct {
+	Name string
+	Type string
+}
+
+func (e *ErrUnknownField) Error() string {
+	return fmt.Sprintf("jsonpatch: %s has no field %s", e.Type, e.Name)
+}
+
+// The built-in field resolvers. A field named in a tag is matched by that
+// name only, a field tagged "-" is never matched and unexported fields are
+// ignored. If no resolver is set, paths are resolved leniently: the name of
+// the field, the names in its json and yaml tags and the name of the field
+// without hyphens and underscores in any case all match. A field whose name
+// is the segment wins, otherwise the first matching field in the order of
+// declaration.
+var (
+	// JSONTags matches fields by the name in their json tag, or their
+	// name if they do not have one, exactly.
+	JSONTags FieldResolver = &fieldResolver{tag: "json"}
+	// CaseInsensitive matches fields in the same way as JSONTags but
+	// accepts names differing in case, as encoding/json does. An exact
+	// match is preferred.
+	CaseInsensitive FieldResolver = &fieldResolver{tag: "json", fold: true}
+	// SnakeCase matches fields which have no json tag by their name in
+	// snake case, e.g. first_name for FirstName.
+	SnakeCase FieldResolver = &fieldResolver{tag: "json", convert: snakeCase}
+	// CamelCase matches fields which have no json tag by their name in
+	// camel case, e.g. firstName for FirstName.
+	CamelCase FieldResolver = &fieldResolver{tag: "json", convert: camelCase}
+)
+
+// TagResolver returns a FieldResolver matching fields by the name in the
+// tag with the given key, e.g. bson or yaml, in the same way as JSONTags.
+func TagResolver(key string) FieldResolver {
+	return &fieldResolver{tag: key}
+}
+
+type fieldResolver struct {
+	tag string
+	// fold enables matching names differing in case.
+	fold bool
+	// convert returns the name of fields without a tag.
+	convert func(string) string
+}
+
+func (r *fieldResolver) ResolveField(t reflect.Type, name string) (string, error) {
+	var exact, folded []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		n := r.name(f)
+		switch {
+		case n == "-":
+		case n == name:
+			exact = append(exact, f.Name)
+		case r.fold && strings.EqualFold(n, name):
+			folded = append(folded, f.Name)
+		}
+	}
+	for _, fields := range [][]string{exact, folded} {
+		if len(fields) == 1 {
+			return fields[0], nil
+		}
+		if len(fields) > 1 {
+			return "", &ErrAmbiguousField{Name: name, Type: t.String(), Fields: fields}
+		}
+	}
+	return "", &ErrUnknownField{Name: name, Type: t.String()}
+}
+
+// name returns the name f is matched by.
+func (r *fieldResolver) name(f reflect.StructField) string {
+	tag := strings.Split(f.Tag.Get(r.tag), ",")[0]
+	if tag != "" {
+		return tag
+	}
+	if r.convert != nil {
+		return r.convert(f.Name)
+	}
+	return f.Name
+}
+
+// snakeCase converts a Go name to snake case, e.g. UserID to user_id.
+func snakeCase(name string) string {
+	words := split(name)
+	for i := range words {
+		words[i] = strings.ToLower(words[i])
+	}
+	return strings.Join(words, "_")
+}
+
+// camelCase converts a Go name to camel case, e.g. UserID to userID.
+func camelCase(name string) string {
+	words := split(name)
+	if len(words) > 0 {
+		words[0] = strings.ToLower(words[0])
+	}
+	return strings.Join(words, "")
+}
+
+// split splits a Go name into its words. A run of upper case letters forms
+// a word of its own, except for its last letter when it is followed by a
+// lower case one, e.g. URLPath is split into URL and Path.
+func split(name string) []string {
+	r := []rune(name)
+	var words []string
+	start := 0
+	for i := 1; i < len(r); i++ {
+		if !unicode.IsUpper(r[i]) {
+			continue
+		}
+		prev := unicode.IsLower(r[i-1]) || unicode.IsDigit(r[i-1])
+		next := i+1 < len(r) && unicode.IsLower(r[i+1]) && unicode.IsUpper(r[i-1])
+		if prev || next {
+			words = append(words, string(r[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(r[start:]))
+}
+
+// lenient resolves fields the same way as paths are resolved when no
+// FieldResolver is set.
+type lenient struct{}
+
+func (lenient) ResolveField(t reflect.Type, name string) (string, error) {
+	field := bestMatch(name, t)
+	if field == "" {
+		return "", &ErrUnknownField{Name: name, Type: t.String()}
+	}
+	return field, nil
+}
+
+// resolvePath returns path with the segments referring to struct fields
+// replaced by the names of the fields, as resolved by r on values of type t.
+// Segments below an interface are left as they are.
+func resolvePath(r FieldResolver, path string, t reflect.Type) (string, error) {
+	nodes := segments(path)
+	for i, node := range nodes {
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		switch t.Kind() {
+		case reflect.Struct:
+			if node == "*" {
+				return "/" + strings.Join(nodes, "/"), nil
+			}
+			name, err := r.ResolveField(t, node)
+			if err != nil {
+				return "", err
+			}
+			f, _ := t.FieldByName(name)
+			nodes[i] = name
+			t = f.Type
+		case reflect.Slice, reflect.Array, reflect.Map:
+			t = t.Elem()
+		default:
+			return "/" + strings.Join(nodes, "/"), nil
+		}
+	}
+	if len(nodes) == 0 {
+		return path, nil
+	}
+	return "/" + strings.Join(nodes, "/"), nil
+}
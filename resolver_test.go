@@ -0,0 +1,110 @@
+package jsonpatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+type testProfile struct {
+	FirstName string
+	UserID    string
+	Nickname  string `json:"nick"`
+	Hidden    string `json:"-"`
+	Email     string `bson:"mail"`
+	Home      string `json:"home"`
+	HOME      string `json:"Home"`
+}
+
+func TestFieldResolvers(t *testing.T) {
+	ty := reflect.TypeOf(testProfile{})
+	tests := []struct {
+		r     FieldResolver
+		name  string
+		field string
+	}{
+		{JSONTags, "FirstName", "FirstName"},
+		{JSONTags, "firstName", ""},
+		{JSONTags, "nick", "Nickname"},
+		{JSONTags, "Nickname", ""},
+		{JSONTags, "Hidden", ""},
+		{CaseInsensitive, "firstname", "FirstName"},
+		{CaseInsensitive, "NICK", "Nickname"},
+		{CaseInsensitive, "Home", "HOME"},
+		{CaseInsensitive, "HOME", ""},
+		{SnakeCase, "first_name", "FirstName"},
+		{SnakeCase, "user_id", "UserID"},
+		{SnakeCase, "nick", "Nickname"},
+		{CamelCase, "firstName", "FirstName"},
+		{CamelCase, "userID", "UserID"},
+		{TagResolver("bson"), "mail", "Email"},
+		{TagResolver("bson"), "Email", ""},
+	}
+	for _, test := range tests {
+		field, err := test.r.ResolveField(ty, test.name)
+		if field != test.field {
+			t.Errorf("%s: expected %q, got %q (%v)", test.name, test.field, field, err)
+		}
+		if test.field == "" && err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+	_, err := CaseInsensitive.ResolveField(ty, "HOME")
+	if _, ok := err.(*ErrAmbiguousField); !ok {
+		t.Fatal("expected ErrAmbiguousField, got", err)
+	}
+}
+
+func TestSplit(t *testing.T) {
+	for name, expected := range map[string]string{
+		"FirstName": "first_name",
+		"UserID":    "user_id",
+		"URLPath":   "url_path",
+		"Address2":  "address2",
+		"A":         "a",
+	} {
+		if s := snakeCase(name); s != expected {
+			t.Errorf("%s: expected %s, got %s", name, expected, s)
+		}
+	}
+}
+
+func TestApplyWithFieldResolver(t *testing.T) {
+	c := testCustomer{Name: "hobbes"}
+	p := []byte(`[{"op": "replace", "path": "/Name", "value": "Calvin"}, {"op": "replace", "path": "/address/city", "value": "Berlin"}]`)
+	err := ApplyWithOptions(p, &c, &ApplyOptions{FieldResolver: JSONTags})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Name != "Calvin" || c.Address.City != "Berlin" {
+		t.Fatal("patch was not applied", c)
+	}
+
+	p = []byte(`[{"op": "replace", "path": "/name", "value": "hobbes"}]`)
+	err = ApplyWithOptions(p, &c, &ApplyOptions{FieldResolver: JSONTags})
+	if _, ok := err.(*ErrUnknownField); !ok {
+		t.Fatal("expected ErrUnknownField, got", err)
+	}
+
+	// the resolved field is patched, not the one tagged with its name
+	var s struct {
+		Home string `json:"HOME"`
+		HOME string `json:"home"`
+	}
+	p = []byte(`[{"op": "replace", "path": "/home", "value": "x"}]`)
+	err = ApplyWithOptions(p, &s, &ApplyOptions{FieldResolver: JSONTags})
+	if err != nil || s.HOME != "x" || s.Home != "" {
+		t.Fatal("unexpected result", s, err)
+	}
+
+	// different spellings of a denied location are denied too
+	for _, opts := range []*ApplyOptions{
+		{Deny: []string{"/name"}},
+		{Deny: []string{"/name"}, FieldResolver: CaseInsensitive},
+	} {
+		p = []byte(`[{"op": "replace", "path": "/NAME", "value": "hobbes"}]`)
+		err = ApplyWithOptions(p, &c, opts)
+		if _, ok := err.(*ErrPermission); !ok {
+			t.Fatal("expected ErrPermission, got", err)
+		}
+	}
+}
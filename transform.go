@@ -0,0 +1,348 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Transform rewrites the concurrent patches a and b, both written against
+// the same value, so that they can be applied in either order with the same
+// result: applying a followed by the returned b' gives the same value as
+// applying b followed by the returned a'.
+//
+// The array indices of the operations are shifted by the elements the other
+// patch inserts and removes. When both patches set the same location, the
+// value of a takes precedence. Operations modifying a location below one
+// the other patch removes or replaces are dropped, as are test operations
+// reading a location the other patch modifies. Removing an array element
+// always wins over modifying it.
+//
+// As Transform does not know the value the patches are going to be applied
+// to, it takes numeric path segments and "-" for array indices. If both
+// patches append to the same array with "-" the order of the elements is
+// ambiguous and ErrAmbiguous is returned.
+//
+// A move is transformed as a remove at its from followed by an add at its
+// path. As the moved value is not known, a move can only be transformed over
+// operations which do not read or modify the moved value, its source or its
+// target, in which case only the array indices are shifted. For any other
+// combination ErrAmbiguous is returned. A copy is transformed in the same way
+// as a test at its from followed by an add at its path.
+func Transform(a, b []Patch) ([]Patch, []Patch, error) {
+	t := &transformer{element: func(path []string) bool {
+		if len(path) == 0 {
+			return false
+		}
+		last := path[len(path)-1]
+		_, ok := index(last)
+		return ok || last == "-"
+	}}
+	a, b, _, err := t.transform(a, b, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, b, nil
+}
+
+// transformer rewrites operations written against the same value so that
+// they can be applied one after another.
+type transformer struct {
+	// element reports whether path points to an element of an array.
+	element func(path []string) bool
+}
+
+// transform rewrites bs to be applied after as, and as to be applied after
+// bs. When operations set the same location the ones of as take precedence.
+//
+// In strict mode every operation of bs which interferes with one of as is
+// left out and reported as a conflict, together with the operations of bs
+// depending on it.
+func (t *transformer) transform(as, bs []Patch, strict bool) ([]Patch, []Patch, []Conflict, error) {
+	as = append([]Patch(nil), as...)
+	orig := append([]Patch(nil), as...)
+	var rebased []Patch
+	var conflicts []Conflict
+	// dropped[k] tells whether bs[k] was left out
+	dropped := make([]bool, len(bs))
+	for k, b := range bs {
+		if strict {
+			if d, ok := depends(b, bs[:k], dropped); ok {
+				conflicts = append(conflicts, Conflict{Patch: b, With: d, Reason: "depends on a conflicting operation"})
+				dropped[k] = true
+				continue
+			}
+		}
+		next := make([]Patch, 0, len(as))
+		nextOrig := make([]Patch, 0, len(as))
+		cur, alive := b, true
+		var conflict *Conflict
+		for i, a := range as {
+			if !alive {
+				next = append(next, a)
+				nextOrig = append(nextOrig, orig[i])
+				continue
+			}
+			a2, keep, reason, err := t.overMove(a, cur, true)
+			if err == nil && (reason == movedConcurrently || reason == copiedConcurrently) && !strict {
+				err = ErrAmbiguous
+			}
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			cur, alive, reason, err = t.overMove(cur, a, false)
+			if err == nil && (reason == movedConcurrently || reason == copiedConcurrently) && !strict {
+				err = ErrAmbiguous
+			}
+			if err != nil {
+				return nil, nil, nil, err
+			}
+			if reason != "" && conflict == nil {
+				conflict = &Conflict{Patch: b, With: orig[i], Reason: reason}
+			}
+			if keep {
+				next = append(next, a2)
+				nextOrig = append(nextOrig, orig[i])
+			}
+		}
+		if strict && conflict != nil {
+			conflicts = append(conflicts, *conflict)
+			dropped[k] = true
+			continue
+		}
+		as, orig = next, nextOrig
+		if alive {
+			rebased = append(rebased, cur)
+		}
+	}
+	return as, rebased, conflicts, nil
+}
+
+// depends returns the operation out of the left out ones among prior, the
+// operations preceding b, which the location of b, or the one it moves or
+// copies, depends on.
+func depends(b Patch, prior []Patch, dropped []bool) (Patch, bool) {
+	if b.Op == "move" || b.Op == "copy" {
+		if d, ok := dependsAt(segments(b.From), prior, dropped); ok {
+			return d, true
+		}
+	}
+	return dependsAt(segments(b.Path), prior, dropped)
+}
+
+// dependsAt is depends for the location path.
+func dependsAt(path []string, prior []Patch, dropped []bool) (Patch, bool) {
+	for i := len(prior) - 1; i >= 0; i-- {
+		prev, ok := backmap(path, &prior[i])
+		if dropped[i] && (!ok || !equalPath(prev, path)) {
+			return prior[i], true
+		}
+		if !ok {
+			// the location is created or read by an operation which
+			// is part of the result
+			return Patch{}, false
+		}
+		path = prev
+	}
+	return Patch{}, false
+}
+
+// over returns x rewritten to be applied after y, both of them written
+// against the same value. If both set the same location, the value of x is
+// kept only if xWins. The second return value is false if x is to be left
+// out, the third one describes how x interferes with y, if it does.
+func (t *transformer) over(x, y Patch, xWins bool) (Patch, bool, string, error) {
+	if !transformable(x.Op) || !transformable(y.Op) {
+		return x, false, "", ErrNotImplemented
+	}
+	if y.Op == "test" {
+		return x, true, "", nil
+	}
+	X, Y := segments(x.Path), segments(y.Path)
+	n := len(Y) - 1
+	if y.Op != "replace" && n >= 0 && len(X) > n && equalPath(X[:n], Y[:n]) && t.element(Y) {
+		// y inserted or removed an element of an array x refers to
+		return t.shift(x, X, y, Y, xWins)
+	}
+	switch {
+	case equalPath(X, Y):
+		return t.same(x, y, xWins)
+	case related(X, Y) && len(Y) < len(X):
+		return x, false, "parent modified concurrently", nil
+	case related(X, Y):
+		// x sets a parent of the location y modified and so overrides it
+		if x.Op == "test" {
+			return x, false, "tests a concurrently modified location", nil
+		}
+		return x, true, "overrides a concurrent change", nil
+	}
+	return x, true, "", nil
+}
+
+// movedConcurrently is the reason given for operations interfering with a
+// concurrent move.
+const movedConcurrently = "interferes with a concurrent move"
+
+// copiedConcurrently is the reason given for operations interfering with a
+// concurrent copy.
+const copiedConcurrently = "interferes with a concurrent copy"
+
+// overMove is over for operations which may be moves or copies. A move is
+// transformed as a remove at its from followed by an add at its path, a copy
+// as a test at its from followed by an add at its path. If any of the two
+// interferes with the other operation beyond shifting array indices, x is
+// left out with the reason movedConcurrently or copiedConcurrently, as
+// transforming it would require knowing the moved or copied value.
+func (t *transformer) overMove(x, y Patch, xWins bool) (Patch, bool, string, error) {
+	switch {
+	case y.Op == "move":
+		remove := Patch{Op: "remove", Path: y.From}
+		add := Patch{Op: "add", Path: y.Path}
+		x2, ok, err := t.clean(x, remove, xWins)
+		if !ok || err != nil {
+			return x, false, movedConcurrently, err
+		}
+		x2, ok, err = t.clean(x2, add, xWins)
+		if !ok || err != nil {
+			return x, false, movedConcurrently, err
+		}
+		return x2, true, "", nil
+	case x.Op == "move":
+		remove := Patch{Op: "remove", Path: x.From}
+		add := Patch{Op: "add", Path: x.Path}
+		remove2, ok, err := t.clean(remove, y, xWins)
+		if !ok || err != nil {
+			return x, false, movedConcurrently, err
+		}
+		// the add is applied after the remove
+		y2, ok, err := t.clean(y, remove, !xWins)
+		if !ok || err != nil {
+			return x, false, movedConcurrently, err
+		}
+		add2, ok, err := t.clean(add, y2, xWins)
+		if !ok || err != nil {
+			return x, false, movedConcurrently, err
+		}
+		x.From, x.Path = remove2.Path, add2.Path
+		return x, true, "", nil
+	case y.Op == "copy":
+		// reading from does not affect x
+		add := Patch{Op: "add", Path: y.Path}
+		x2, ok, err := t.clean(x, add, xWins)
+		if !ok || err != nil {
+			return x, false, copiedConcurrently, err
+		}
+		return x2, true, "", nil
+	case x.Op == "copy":
+		test := Patch{Op: "test", Path: x.From}
+		add := Patch{Op: "add", Path: x.Path}
+		test2, ok, err := t.clean(test, y, xWins)
+		if !ok || err != nil {
+			return x, false, copiedConcurrently, err
+		}
+		add2, ok, err := t.clean(add, y, xWins)
+		if !ok || err != nil {
+			return x, false, copiedConcurrently, err
+		}
+		x.From, x.Path = test2.Path, add2.Path
+		return x, true, "", nil
+	}
+	return t.over(x, y, xWins)
+}
+
+// clean returns x transformed over y. The second return value is false if
+// they interfere with each other beyond shifting array indices.
+func (t *transformer) clean(x, y Patch, xWins bool) (Patch, bool, error) {
+	x2, keep, reason, err := t.overMove(x, y, xWins)
+	if err != nil || !keep || reason != "" {
+		return x, false, err
+	}
+	return x2, true, nil
+}
+
+// shift adjusts the index of x after y inserted or removed an element of
+// the array the n-th segment of X indexes.
+func (t *transformer) shift(x Patch, X []string, y Patch, Y []string, xWins bool) (Patch, bool, string, error) {
+	n := len(Y) - 1
+	insert := x.Op == "add" && len(X) == n+1
+	if Y[n] == "-" {
+		if insert && X[n] == "-" {
+			// the order of the appended elements cannot be decided
+			// without knowing the length of the array
+			return x, false, "", ErrAmbiguous
+		}
+		return x, true, "", nil
+	}
+	i, ok := index(Y[n])
+	j, ok2 := index(X[n])
+	if !ok || !ok2 {
+		return x, true, "", nil
+	}
+	switch y.Op {
+	case "add":
+		if j > i || (j == i && !(insert && xWins)) {
+			j++
+		}
+	case "remove":
+		if j > i {
+			j--
+		} else if j == i && !insert {
+			if x.Op == "remove" && len(X) == n+1 {
+				// both removed the same element
+				return x, false, "", nil
+			}
+			return x, false, "element removed concurrently", nil
+		}
+	}
+	X[n] = strconv.Itoa(j)
+	x.Path = "/" + strings.Join(X, "/")
+	return x, true, "", nil
+}
+
+// same transforms x over y which modified the same location, unless it
+// inserted or removed an array element.
+func (t *transformer) same(x, y Patch, xWins bool) (Patch, bool, string, error) {
+	if x.Op == "test" {
+		return x, false, "tests a concurrently modified location", nil
+	}
+	if x.Op == "add" && t.element(segments(x.Path)) {
+		// inserting an element leaves the one y modified alone
+		return x, true, "", nil
+	}
+	if y.Op == "remove" {
+		if x.Op == "remove" {
+			return x, false, "", nil
+		}
+		// the location has to be created again
+		x.Op = "add"
+		return x, xWins, "modifies a concurrently removed location", nil
+	}
+	if x.Op == "remove" {
+		// Replacing an element cannot bring back one which was removed
+		// from an array, thus removing it always wins.
+		return x, xWins || t.element(segments(x.Path)), "removes a concurrently modified location", nil
+	}
+	if equalValue(x.Value, y.Value) {
+		return x, false, "", nil
+	}
+	return x, xWins, "both set different values", nil
+}
+
+// transformable reports whether operations of type op can be transformed.
+func transformable(op string) bool {
+	switch op {
+	case "add", "remove", "replace", "test", "move", "copy":
+		return true
+	}
+	return false
+}
+
+// equalValue reports whether the JSON values a and b are the same.
+func equalValue(a, b json.RawMessage) bool {
+	var x, y interface{}
+	if json.Unmarshal(a, &x) != nil || json.Unmarshal(b, &y) != nil {
+		return false
+	}
+	return reflect.DeepEqual(x, y)
+}
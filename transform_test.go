@@ -0,0 +1,125 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	tests := []struct {
+		a, b           string
+		aPrime, bPrime string
+	}{
+		{
+			`[{"op": "add", "path": "/tags/1", "value": "a"}]`,
+			`[{"op": "add", "path": "/tags/1", "value": "b"}]`,
+			`[{"op": "add", "path": "/tags/1", "value": "a"}]`,
+			`[{"op": "add", "path": "/tags/2", "value": "b"}]`,
+		},
+		{
+			`[{"op": "remove", "path": "/tags/0"}]`,
+			`[{"op": "replace", "path": "/tags/2", "value": "b"}]`,
+			`[{"op": "remove", "path": "/tags/0"}]`,
+			`[{"op": "replace", "path": "/tags/1", "value": "b"}]`,
+		},
+		{
+			`[{"op": "remove", "path": "/items/1"}]`,
+			`[{"op": "replace", "path": "/items/1/price", "value": 5}]`,
+			`[{"op": "remove", "path": "/items/1"}]`,
+			`null`,
+		},
+		{
+			`[{"op": "replace", "path": "/name", "value": "a"}]`,
+			`[{"op": "replace", "path": "/name", "value": "b"}, {"op": "test", "path": "/name", "value": "b"}]`,
+			`[{"op": "replace", "path": "/name", "value": "a"}]`,
+			`null`,
+		},
+		{
+			`[{"op": "remove", "path": "/m/a"}]`,
+			`[{"op": "replace", "path": "/m/a", "value": "b"}]`,
+			`[{"op": "remove", "path": "/m/a"}]`,
+			`null`,
+		},
+		{
+			`[{"op": "move", "from": "/tags/0", "path": "/tags/-"}]`,
+			`[{"op": "add", "path": "/tags/0", "value": "b"}, {"op": "remove", "path": "/tags/3"}]`,
+			`[{"op": "move", "from": "/tags/1", "path": "/tags/-"}]`,
+			`[{"op": "add", "path": "/tags/0", "value": "b"}, {"op": "remove", "path": "/tags/2"}]`,
+		},
+		{
+			`[{"op": "copy", "from": "/tags/0", "path": "/tags/1"}]`,
+			`[{"op": "remove", "path": "/tags/3"}]`,
+			`[{"op": "copy", "from": "/tags/0", "path": "/tags/1"}]`,
+			`[{"op": "remove", "path": "/tags/4"}]`,
+		},
+	}
+	for _, test := range tests {
+		var a, b, aPrime, bPrime []Patch
+		json.Unmarshal([]byte(test.a), &a)
+		json.Unmarshal([]byte(test.b), &b)
+		json.Unmarshal([]byte(test.aPrime), &aPrime)
+		json.Unmarshal([]byte(test.bPrime), &bPrime)
+		x, y, err := Transform(a, b)
+		if err != nil {
+			t.Fatal(test.a, test.b, err)
+		}
+		if !reflect.DeepEqual(x, aPrime) || !reflect.DeepEqual(y, bPrime) {
+			t.Errorf("transforming %s and %s\ngot      %v %v\nexpected %v %v", test.a, test.b, x, y, aPrime, bPrime)
+		}
+	}
+
+	a := []Patch{{Op: "add", Path: "/tags/-", Value: json.RawMessage(`"a"`)}}
+	_, _, err := Transform(a, a)
+	if err != ErrAmbiguous {
+		t.Fatal("expected ErrAmbiguous, got", err)
+	}
+	a = []Patch{{Op: "move", From: "/m/a", Path: "/m/b"}}
+	b := []Patch{{Op: "replace", Path: "/m/a", Value: json.RawMessage(`"c"`)}}
+	_, _, err = Transform(a, b)
+	if err != ErrAmbiguous {
+		t.Fatal("expected ErrAmbiguous, got", err)
+	}
+	a = []Patch{{Op: "copy", From: "/m/a", Path: "/m/b"}}
+	_, _, err = Transform(a, b)
+	if err != ErrAmbiguous {
+		t.Fatal("expected ErrAmbiguous, got", err)
+	}
+}
+
+func TestTransformConverges(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 2000; i++ {
+		var base testDoc
+		randomPatch(t, r, &base, 6)
+
+		da, db := base, base
+		pa := randomPatch(t, r, &da, 4)
+		pb := randomPatch(t, r, &db, 4)
+		var a, b []Patch
+		json.Unmarshal(pa, &a)
+		json.Unmarshal(pb, &b)
+		aPrime, bPrime, err := Transform(a, b)
+		if err == ErrAmbiguous {
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		ab, ba := da, db
+		data, _ := json.Marshal(bPrime)
+		err = Apply(data, &ab)
+		if err != nil {
+			t.Fatalf("%s\ntransformed from %s\nover %s\nfailed: %v", data, pb, pa, err)
+		}
+		data, _ = json.Marshal(aPrime)
+		err = Apply(data, &ba)
+		if err != nil {
+			t.Fatalf("%s\ntransformed from %s\nover %s\nfailed: %v", data, pa, pb, err)
+		}
+		if !reflect.DeepEqual(ab.normalize(), ba.normalize()) {
+			t.Fatalf("%s and %s diverge\ngot      %+v\nexpected %+v", pa, pb, ba, ab)
+		}
+	}
+}
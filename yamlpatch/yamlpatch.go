@@ -0,0 +1,449 @@
+// Package yamlpatch applies JSON patches as defined in RFC 6902 to YAML
+// documents.
+//
+// The mappings and sequences of the document take part in patching as
+// jsonpatch containers, so the patch is applied by jsonpatch.Apply to the
+// untyped document while the node tree keeps the order of the keys and the
+// comments. Go structs with yaml tags can be patched with jsonpatch.Apply
+// directly.
+package yamlpatch
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/optiopay/jsonpatch"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	ErrEmptyDocument = errors.New("yamlpatch: empty document")
+	ErrNotFound      = errors.New("yamlpatch: location not found")
+)
+
+// Apply applies a JSON patch to the YAML document doc and returns the
+// patched document. It fails without applying any of the operations if
+// one of them fails.
+//
+// Aliases are followed when reading a location. A location reached through
+// an alias is modified in a copy of the anchored node which replaces the
+// alias, so that the other references to the anchor are left alone.
+func Apply(patch, doc []byte) ([]byte, error) {
+	var patches []jsonpatch.Patch
+	err := json.Unmarshal(patch, &patches)
+	if err != nil {
+		return nil, err
+	}
+	var root yaml.Node
+	err = yaml.Unmarshal(doc, &root)
+	if err != nil {
+		return nil, err
+	}
+	if root.Kind != yaml.DocumentNode || len(root.Content) == 0 {
+		return nil, ErrEmptyDocument
+	}
+	for i := range patches {
+		err = apply(&patches[i], &root)
+		if err == jsonpatch.ErrNodeNil {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	var out bytes.Buffer
+	e := yaml.NewEncoder(&out)
+	e.SetIndent(2)
+	err = e.Encode(&root)
+	if err != nil {
+		return nil, err
+	}
+	err = e.Close()
+	if err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func apply(p *jsonpatch.Patch, root *yaml.Node) error {
+	if p.Op == "copy" || p.Op == "move" {
+		return transfer(p, root)
+	}
+	if p.Path == "" {
+		return applyRoot(p, root)
+	}
+	if p.Op != "test" {
+		unalias(p.Path, root.Content[0])
+	}
+	data, err := json.Marshal([]jsonpatch.Patch{*p})
+	if err != nil {
+		return err
+	}
+	doc := value(root.Content[0])
+	return jsonpatch.Apply(data, &doc)
+}
+
+// applyRoot applies an operation on the whole document.
+func applyRoot(p *jsonpatch.Patch, root *yaml.Node) error {
+	switch p.Op {
+	case "add", "replace":
+		n, err := node(p.Value)
+		if err != nil {
+			return err
+		}
+		keepComments(n, root.Content[0])
+		root.Content[0] = n
+		return nil
+	case "test":
+		return equal(root.Content[0], p.Value)
+	case "remove":
+		return ErrEmptyDocument
+	}
+	return &jsonpatch.ErrUnknownOp{Op: p.Op}
+}
+
+// transfer applies the copy or move p by cloning the node at p.From, so that
+// the order of its keys and its comments are kept.
+func transfer(p *jsonpatch.Patch, root *yaml.Node) error {
+	if p.Op == "move" {
+		if p.Path == p.From {
+			return nil
+		}
+		if strings.HasPrefix(p.Path+"/", p.From+"/") {
+			// a value cannot be moved into one of its children
+			return jsonpatch.ErrIncorrectIndex
+		}
+	}
+	from, err := find(root.Content[0], p.From)
+	if err != nil {
+		return err
+	}
+	n := clone(from)
+	if p.Op == "move" {
+		err = apply(&jsonpatch.Patch{Op: "remove", Path: p.From}, root)
+		if err != nil {
+			return err
+		}
+	}
+	if p.Path == "" {
+		keepComments(n, root.Content[0])
+		root.Content[0] = n
+		return nil
+	}
+	unalias(p.Path, root.Content[0])
+	i := strings.LastIndex(p.Path, "/")
+	parent, err := find(root.Content[0], p.Path[:i])
+	if err != nil {
+		return err
+	}
+	return insert(parent, unescaper.Replace(p.Path[i+1:]), n)
+}
+
+// find returns the node at the JSON Pointer within n, following aliases.
+func find(n *yaml.Node, pointer string) (*yaml.Node, error) {
+	if pointer == "" {
+		return resolve(n), nil
+	}
+	if pointer[0] != '/' {
+		return nil, &jsonpatch.ErrInvalidPointer{Pointer: pointer}
+	}
+	for _, key := range strings.Split(pointer[1:], "/") {
+		key = unescaper.Replace(key)
+		n = resolve(n)
+		switch n.Kind {
+		case yaml.MappingNode:
+			i := member(n, key)
+			if i < 0 {
+				return nil, ErrNotFound
+			}
+			n = n.Content[i+1]
+		case yaml.SequenceNode:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(n.Content) {
+				return nil, jsonpatch.ErrIncorrectIndex
+			}
+			n = n.Content[i]
+		default:
+			return nil, ErrNotFound
+		}
+	}
+	return resolve(n), nil
+}
+
+// insert adds the node n as the element key of parent, replacing the
+// existing element of a mapping.
+func insert(parent *yaml.Node, key string, n *yaml.Node) error {
+	switch parent.Kind {
+	case yaml.MappingNode:
+		i := member(parent, key)
+		if i >= 0 {
+			keepComments(n, parent.Content[i+1])
+			parent.Content[i+1] = n
+			return nil
+		}
+		k := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}
+		parent.Content = append(parent.Content, k, n)
+	case yaml.SequenceNode:
+		pos := len(parent.Content)
+		if key != "-" {
+			var err error
+			pos, err = strconv.Atoi(key)
+			if err != nil || pos < 0 || pos > len(parent.Content) {
+				return jsonpatch.ErrIncorrectIndex
+			}
+		}
+		content := make([]*yaml.Node, 0, len(parent.Content)+1)
+		content = append(content, parent.Content[:pos]...)
+		content = append(content, n)
+		parent.Content = append(content, parent.Content[pos:]...)
+	default:
+		return ErrNotFound
+	}
+	return nil
+}
+
+// unalias replaces the aliases on the way to the location pointer within n
+// by copies of the nodes they refer to.
+func unalias(pointer string, n *yaml.Node) {
+	nodes := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for _, key := range nodes[:len(nodes)-1] {
+		key = unescaper.Replace(key)
+		n = resolve(n)
+		var i int
+		switch n.Kind {
+		case yaml.MappingNode:
+			i = member(n, key) + 1
+			if i == 0 {
+				return
+			}
+		case yaml.SequenceNode:
+			var err error
+			i, err = strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(n.Content) {
+				return
+			}
+		default:
+			return
+		}
+		if n.Content[i].Kind == yaml.AliasNode {
+			c := clone(n.Content[i].Alias)
+			keepComments(c, n.Content[i])
+			n.Content[i] = c
+		}
+		n = n.Content[i]
+	}
+}
+
+var unescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// clone returns a deep copy of n without its anchors.
+func clone(n *yaml.Node) *yaml.Node {
+	c := *n
+	c.Anchor = ""
+	c.Content = make([]*yaml.Node, len(n.Content))
+	for i, child := range n.Content {
+		c.Content[i] = clone(child)
+	}
+	return &c
+}
+
+// resolve follows aliases to the node they refer to.
+func resolve(n *yaml.Node) *yaml.Node {
+	for n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
+
+// value returns the container of the mapping or sequence n, or the decoded
+// value of any other node.
+func value(n *yaml.Node) interface{} {
+	n = resolve(n)
+	switch n.Kind {
+	case yaml.MappingNode:
+		return &mapping{n}
+	case yaml.SequenceNode:
+		return &sequence{n}
+	}
+	var v interface{}
+	err := n.Decode(&v)
+	if err != nil {
+		return nil
+	}
+	return v
+}
+
+// member returns the index of the key node of the mapping n, or -1.
+func member(n *yaml.Node, key string) int {
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// mapping is a YAML mapping taking part in patching as a container.
+type mapping struct {
+	n *yaml.Node
+}
+
+func (m *mapping) Get(key string) (interface{}, bool) {
+	i := member(m.n, key)
+	if i < 0 {
+		return nil, false
+	}
+	return value(m.n.Content[i+1]), true
+}
+
+func (m *mapping) Add(key string, v json.RawMessage) error {
+	n, err := node(v)
+	if err != nil {
+		return err
+	}
+	return insert(m.n, key, n)
+}
+
+func (m *mapping) Remove(key string) error {
+	i := member(m.n, key)
+	if i < 0 {
+		return ErrNotFound
+	}
+	// the key node precedes the value
+	m.n.Content = append(m.n.Content[:i], m.n.Content[i+2:]...)
+	return nil
+}
+
+func (m *mapping) Replace(key string, v json.RawMessage) error {
+	i := member(m.n, key)
+	if i < 0 {
+		return ErrNotFound
+	}
+	return replaceAt(m.n, i+1, v)
+}
+
+func (m *mapping) MarshalJSON() ([]byte, error) {
+	return marshal(m.n)
+}
+
+// sequence is a YAML sequence taking part in patching as a container.
+type sequence struct {
+	n *yaml.Node
+}
+
+func (s *sequence) Get(key string) (interface{}, bool) {
+	i, err := s.index(key)
+	if err != nil {
+		return nil, false
+	}
+	return value(s.n.Content[i]), true
+}
+
+func (s *sequence) Add(key string, v json.RawMessage) error {
+	n, err := node(v)
+	if err != nil {
+		return err
+	}
+	return insert(s.n, key, n)
+}
+
+func (s *sequence) Remove(key string) error {
+	i, err := s.index(key)
+	if err != nil {
+		return err
+	}
+	s.n.Content = append(s.n.Content[:i], s.n.Content[i+1:]...)
+	return nil
+}
+
+func (s *sequence) Replace(key string, v json.RawMessage) error {
+	i, err := s.index(key)
+	if err != nil {
+		return err
+	}
+	return replaceAt(s.n, i, v)
+}
+
+func (s *sequence) MarshalJSON() ([]byte, error) {
+	return marshal(s.n)
+}
+
+// index returns the index of the element key of the sequence.
+func (s *sequence) index(key string) (int, error) {
+	i, err := strconv.Atoi(key)
+	if err != nil || i < 0 || i >= len(s.n.Content) {
+		return 0, jsonpatch.ErrIncorrectIndex
+	}
+	return i, nil
+}
+
+// replaceAt replaces the i-th node of the content of parent with the JSON
+// value, keeping its comments.
+func replaceAt(parent *yaml.Node, i int, v json.RawMessage) error {
+	n, err := node(v)
+	if err != nil {
+		return err
+	}
+	keepComments(n, parent.Content[i])
+	parent.Content[i] = n
+	return nil
+}
+
+// node returns the YAML node of the JSON value.
+func node(value json.RawMessage) (*yaml.Node, error) {
+	var v interface{}
+	err := json.Unmarshal(value, &v)
+	if err != nil {
+		return nil, err
+	}
+	var n yaml.Node
+	err = n.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// marshal returns the JSON encoding of the value of n.
+func marshal(n *yaml.Node) ([]byte, error) {
+	var x interface{}
+	err := n.Decode(&x)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(x)
+}
+
+// equal returns jsonpatch.ErrTestFailed unless n holds the JSON value.
+func equal(n *yaml.Node, value json.RawMessage) error {
+	// YAML and JSON decode numbers into different types, thus the value
+	// of the node is compared in its JSON representation
+	data, err := marshal(n)
+	if err != nil {
+		return err
+	}
+	var a, b interface{}
+	err = json.Unmarshal(data, &a)
+	if err != nil {
+		return err
+	}
+	err = json.Unmarshal(value, &b)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(a, b) {
+		return jsonpatch.ErrTestFailed
+	}
+	return nil
+}
+
+// keepComments moves the comments of the node old to its replacement n.
+func keepComments(n, old *yaml.Node) {
+	n.HeadComment = old.HeadComment
+	n.LineComment = old.LineComment
+	n.FootComment = old.FootComment
+}
@@ -0,0 +1,173 @@
+package yamlpatch
+
+import (
+	"testing"
+
+	"github.com/optiopay/jsonpatch"
+)
+
+const deployment = `# the web frontend
+name: web
+replicas: 2 # scaled by the autoscaler
+image: nginx:1.19
+ports:
+  - 80
+  - 443
+env:
+  MODE: production
+`
+
+func TestApply(t *testing.T) {
+	p := []byte(`[
+		{"op": "test", "path": "/replicas", "value": 2},
+		{"op": "replace", "path": "/replicas", "value": 3},
+		{"op": "add", "path": "/ports/1", "value": 8080},
+		{"op": "remove", "path": "/image"},
+		{"op": "add", "path": "/env/DEBUG", "value": "false"},
+		{"op": "add", "path": "/labels", "value": {"team": "web"}}
+	]`)
+	data, err := Apply(p, []byte(deployment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `# the web frontend
+name: web
+replicas: 3 # scaled by the autoscaler
+ports:
+  - 80
+  - 8080
+  - 443
+env:
+  MODE: production
+  DEBUG: "false"
+labels:
+  team: web
+`
+	if string(data) != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, data)
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	tests := []struct {
+		patch string
+		err   error
+	}{
+		{`[{"op": "test", "path": "/name", "value": "api"}]`, jsonpatch.ErrTestFailed},
+		{`[{"op": "replace", "path": "/ports/5", "value": 1}]`, jsonpatch.ErrIncorrectIndex},
+		{`[{"op": "remove", "path": "/volumes/0"}]`, ErrNotFound},
+		{`[{"op": "move", "from": "/volumes", "path": "/title"}]`, ErrNotFound},
+		{`[{"op": "remove", "path": ""}]`, ErrEmptyDocument},
+		{`[{"op": "add", "path": "/name/x", "value": 1}]`, ErrNotFound},
+		{`[{"op": "copy", "from": "/name/x", "path": "/x"}]`, ErrNotFound},
+		{`[{"op": "move", "from": "/env", "path": "/env/x"}]`, jsonpatch.ErrIncorrectIndex},
+	}
+	for _, test := range tests {
+		_, err := Apply([]byte(test.patch), []byte(deployment))
+		if err != test.err {
+			t.Errorf("%s: expected %v, got %v", test.patch, test.err, err)
+		}
+	}
+	_, err := Apply([]byte(`[]`), []byte(""))
+	if err != ErrEmptyDocument {
+		t.Fatal("expected ErrEmptyDocument, got", err)
+	}
+}
+
+func TestApplyMoveAndCopy(t *testing.T) {
+	p := []byte(`[
+		{"op": "move", "from": "/image", "path": "/env/IMAGE"},
+		{"op": "copy", "from": "/ports/1", "path": "/ports/0"},
+		{"op": "move", "from": "/name", "path": "/title"},
+		{"op": "test", "path": "/env", "value": {"MODE": "production", "IMAGE": "nginx:1.19"}}
+	]`)
+	data, err := Apply(p, []byte(deployment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the comment of the moved key is removed with it
+	expected := `replicas: 2 # scaled by the autoscaler
+ports:
+  - 443
+  - 80
+  - 443
+env:
+  MODE: production
+  IMAGE: nginx:1.19
+title: web
+`
+	if string(data) != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, data)
+	}
+
+	data, err = Apply([]byte(`[{"op": "copy", "from": "/env", "path": ""}]`), []byte(deployment))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "MODE: production\n" {
+		t.Fatalf("unexpected document\n%s", data)
+	}
+}
+
+func TestApplyMoveKeepsNodes(t *testing.T) {
+	doc := []byte(`a:
+  # keep z
+  z: 1
+  y: 2
+  x: 3
+b: 4
+`)
+	p := []byte(`[
+		{"op": "copy", "from": "/a", "path": "/b"},
+		{"op": "move", "from": "/a", "path": "/c"}
+	]`)
+	data, err := Apply(p, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `b:
+  # keep z
+  z: 1
+  y: 2
+  x: 3
+c:
+  # keep z
+  z: 1
+  y: 2
+  x: 3
+`
+	if string(data) != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, data)
+	}
+}
+
+func TestApplyAliases(t *testing.T) {
+	doc := []byte(`defaults: &defaults
+  timeout: 30
+  retries: 3
+web: *defaults
+api: *defaults
+`)
+	p := []byte(`[
+		{"op": "test", "path": "/web/timeout", "value": 30},
+		{"op": "replace", "path": "/web/timeout", "value": 60},
+		{"op": "move", "from": "/api/retries", "path": "/api/attempts"}
+	]`)
+	data, err := Apply(p, doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `defaults: &defaults
+  timeout: 30
+  retries: 3
+web:
+  timeout: 60
+  retries: 3
+api:
+  timeout: 30
+  attempts: 3
+`
+	if string(data) != expected {
+		t.Fatalf("expected\n%s\ngot\n%s", expected, data)
+	}
+}